@@ -0,0 +1,113 @@
+package backoff
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultMaxAttempts  = 5
+	defaultInitialDelay = time.Millisecond * 100
+	defaultMaxDelay     = time.Second * 30
+	rejectedExecution   = "es_rejected_execution_exception"
+	tooManyRequests     = 429
+)
+
+// Backoff represents an exponential backoff with full jitter used to retry
+// rejected bulk items.
+type Backoff struct {
+	maxAttempts  int
+	initialDelay time.Duration
+	maxDelay     time.Duration
+}
+
+// OptionFunc is a function that configures a Backoff. It is used in New.
+type OptionFunc func(*Backoff) error
+
+// New instantiates and configures a new Backoff instance.
+func New(options ...OptionFunc) (*Backoff, error) {
+	b := &Backoff{
+		maxAttempts:  defaultMaxAttempts,
+		initialDelay: defaultInitialDelay,
+		maxDelay:     defaultMaxDelay,
+	}
+	for _, option := range options {
+		if err := option(b); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Default returns a Backoff using the default configuration.
+func Default() *Backoff {
+	b, _ := New()
+	return b
+}
+
+// SetMaxAttempts sets the maximum number of attempts, including the first,
+// before a retryable item is considered failed.
+func SetMaxAttempts(maxAttempts int) OptionFunc {
+	return func(b *Backoff) error {
+		b.maxAttempts = maxAttempts
+		return nil
+	}
+}
+
+// SetInitialDelay sets the delay before the first retry.
+func SetInitialDelay(delay time.Duration) OptionFunc {
+	return func(b *Backoff) error {
+		b.initialDelay = delay
+		return nil
+	}
+}
+
+// SetMaxDelay sets the maximum delay between retries.
+func SetMaxDelay(delay time.Duration) OptionFunc {
+	return func(b *Backoff) error {
+		b.maxDelay = delay
+		return nil
+	}
+}
+
+// MaxAttempts returns the maximum number of attempts.
+func (b *Backoff) MaxAttempts() int {
+	return b.maxAttempts
+}
+
+// Delay returns the delay to wait after the provided attempt. The delay is
+// chosen uniformly between zero and the exponential delay, capped at the
+// maximum delay.
+func (b *Backoff) Delay(attempt int) time.Duration {
+	delay := b.initialDelay
+	for i := 1; i < attempt && delay < b.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > b.maxDelay {
+		delay = b.maxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// Wait blocks for the delay of the provided attempt, or until the context is
+// cancelled, in which case the context error is returned.
+func (b *Backoff) Wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(b.Delay(attempt))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// IsRetryable returns true if a bulk item with the provided status and error
+// type was rejected due to back pressure and can be retried.
+func IsRetryable(status int, typ string) bool {
+	return status == tooManyRequests || typ == rejectedExecution
+}
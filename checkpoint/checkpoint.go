@@ -0,0 +1,54 @@
+package checkpoint
+
+// Store represents a persistent store for ingest checkpoints.
+type Store interface {
+	Load() (*Checkpoint, error)
+	Save(*Checkpoint) error
+}
+
+// Checkpoint represents the acknowledged progress of an ingest, keyed by
+// source name, along with the index being ingested into.
+type Checkpoint struct {
+	Index   string             `json:"index,omitempty"`
+	Sources map[string]*Source `json:"sources"`
+}
+
+// Source represents the acknowledged progress within a single source. Bytes
+// is the offset within the decompressed stream.
+type Source struct {
+	Lines    int64 `json:"lines"`
+	Bytes    int64 `json:"bytes"`
+	Complete bool  `json:"complete"`
+}
+
+// New instantiates a new empty checkpoint.
+func New() *Checkpoint {
+	return &Checkpoint{
+		Sources: make(map[string]*Source),
+	}
+}
+
+// Get returns the progress for the named source. If there is no progress
+// recorded, a zero value source is returned.
+func (c *Checkpoint) Get(name string) Source {
+	source, ok := c.Sources[name]
+	if !ok {
+		return Source{}
+	}
+	return *source
+}
+
+// IsEmpty returns true if no progress has been recorded.
+func (c *Checkpoint) IsEmpty() bool {
+	return len(c.Sources) == 0
+}
+
+func (c *Checkpoint) copy() *Checkpoint {
+	cp := New()
+	cp.Index = c.Index
+	for name, source := range c.Sources {
+		s := *source
+		cp.Sources[name] = &s
+	}
+	return cp
+}
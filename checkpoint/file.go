@@ -0,0 +1,67 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// FileStore represents a checkpoint store backed by a local JSON file.
+type FileStore struct {
+	path string
+}
+
+// NewFileStore instantiates a new JSON file checkpoint store.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{
+		path: path,
+	}
+}
+
+// Load reads the checkpoint from the file. If the file does not exist an
+// empty checkpoint is returned.
+func (s *FileStore) Load() (*Checkpoint, error) {
+	bytes, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := New()
+	err = json.Unmarshal(bytes, checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint.Sources == nil {
+		checkpoint.Sources = make(map[string]*Source)
+	}
+	return checkpoint, nil
+}
+
+// Save writes the checkpoint to the file. The file is written to a
+// temporary location first and then renamed so that a crash never leaves a
+// partially written checkpoint behind.
+func (s *FileStore) Save(checkpoint *Checkpoint) error {
+	bytes, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(bytes)
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
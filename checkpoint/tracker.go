@@ -0,0 +1,131 @@
+package checkpoint
+
+import (
+	"sync"
+)
+
+// Tracker tracks the acknowledgement of bulk requests per source and
+// persists the furthest contiguous acknowledged offset to a store. Bulk
+// requests may be acknowledged out of order, so a source only advances once
+// every preceding request for it has been acknowledged.
+type Tracker struct {
+	store      Store
+	checkpoint *Checkpoint
+	mu         sync.Mutex
+	err        error
+}
+
+// Cursor tracks the pending bulk requests for a single source.
+type Cursor struct {
+	tracker *Tracker
+	name    string
+	pending []*pending
+	done    bool
+	final   Source
+}
+
+type pending struct {
+	offset Source
+	acked  bool
+}
+
+// NewTracker instantiates a new tracker starting from the provided
+// checkpoint.
+func NewTracker(store Store, checkpoint *Checkpoint) *Tracker {
+	if checkpoint == nil {
+		checkpoint = New()
+	}
+	return &Tracker{
+		store:      store,
+		checkpoint: checkpoint.copy(),
+	}
+}
+
+// Get returns the acknowledged progress for the named source.
+func (t *Tracker) Get(name string) Source {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.checkpoint.Get(name)
+}
+
+// Open returns a cursor for tracking the bulk requests of the named source.
+func (t *Tracker) Open(name string) *Cursor {
+	return &Cursor{
+		tracker: t,
+		name:    name,
+	}
+}
+
+// Err returns the first error encountered while saving the checkpoint.
+func (t *Tracker) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+func (t *Tracker) save() {
+	// NOTE: expects the mutex to be held
+	err := t.store.Save(t.checkpoint)
+	if err != nil && t.err == nil {
+		t.err = err
+	}
+}
+
+// Add registers a bulk request ending at the provided line and byte offset
+// within the source. The returned function must be called once the request
+// has been acknowledged, with any error that occurred.
+func (c *Cursor) Add(lines, bytes int64) func(error) {
+	t := c.tracker
+	t.mu.Lock()
+	p := &pending{
+		offset: Source{
+			Lines: lines,
+			Bytes: bytes,
+		},
+	}
+	c.pending = append(c.pending, p)
+	t.mu.Unlock()
+	return func(err error) {
+		if err != nil {
+			// never advance past a failed request
+			return
+		}
+		t.mu.Lock()
+		p.acked = true
+		c.advance()
+		t.mu.Unlock()
+	}
+}
+
+// Done flags that all bulk requests for the source have been added and that
+// the source was read through to the provided final offset.
+func (c *Cursor) Done(lines, bytes int64) {
+	t := c.tracker
+	t.mu.Lock()
+	c.done = true
+	c.final = Source{
+		Lines:    lines,
+		Bytes:    bytes,
+		Complete: true,
+	}
+	c.advance()
+	t.mu.Unlock()
+}
+
+func (c *Cursor) advance() {
+	// NOTE: expects the tracker mutex to be held
+	var offset *Source
+	for len(c.pending) > 0 && c.pending[0].acked {
+		offset = &c.pending[0].offset
+		c.pending = c.pending[1:]
+	}
+	if c.done && len(c.pending) == 0 {
+		offset = &c.final
+	}
+	if offset == nil {
+		return
+	}
+	source := *offset
+	c.tracker.checkpoint.Sources[c.name] = &source
+	c.tracker.save()
+}
@@ -0,0 +1,187 @@
+package checkpoint
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// memoryStore records every saved checkpoint.
+type memoryStore struct {
+	saves []*Checkpoint
+	err   error
+}
+
+func (s *memoryStore) Load() (*Checkpoint, error) {
+	if len(s.saves) == 0 {
+		return New(), nil
+	}
+	return s.saves[len(s.saves)-1].copy(), nil
+}
+
+func (s *memoryStore) Save(checkpoint *Checkpoint) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.saves = append(s.saves, checkpoint.copy())
+	return nil
+}
+
+func expectSource(t *testing.T, tracker *Tracker, name string, expected Source) {
+	t.Helper()
+	if source := tracker.Get(name); source != expected {
+		t.Errorf("expected source `%s` at %+v, got %+v", name, expected, source)
+	}
+}
+
+func TestCursorOutOfOrderAcks(t *testing.T) {
+	store := &memoryStore{}
+	tracker := NewTracker(store, nil)
+	cursor := tracker.Open("a")
+	first := cursor.Add(10, 100)
+	second := cursor.Add(20, 200)
+	third := cursor.Add(30, 300)
+	// the last request cannot advance the cursor past the pending ones
+	third(nil)
+	expectSource(t, tracker, "a", Source{})
+	if len(store.saves) != 0 {
+		t.Errorf("expected no saved checkpoints, got %d", len(store.saves))
+	}
+	first(nil)
+	expectSource(t, tracker, "a", Source{Lines: 10, Bytes: 100})
+	// the second request completes the contiguous range through the third
+	second(nil)
+	expectSource(t, tracker, "a", Source{Lines: 30, Bytes: 300})
+	cursor.Done(30, 300)
+	expectSource(t, tracker, "a", Source{Lines: 30, Bytes: 300, Complete: true})
+	if len(store.saves) != 3 {
+		t.Errorf("expected 3 saved checkpoints, got %d", len(store.saves))
+	}
+}
+
+func TestCursorFailedAck(t *testing.T) {
+	tracker := NewTracker(&memoryStore{}, nil)
+	cursor := tracker.Open("a")
+	first := cursor.Add(10, 100)
+	second := cursor.Add(20, 200)
+	third := cursor.Add(30, 300)
+	first(nil)
+	second(errors.New("rejected"))
+	third(nil)
+	// the failed request holds the cursor back
+	expectSource(t, tracker, "a", Source{Lines: 10, Bytes: 100})
+	cursor.Done(30, 300)
+	expectSource(t, tracker, "a", Source{Lines: 10, Bytes: 100})
+}
+
+func TestCursorDoneBeforeAcks(t *testing.T) {
+	tracker := NewTracker(&memoryStore{}, nil)
+	cursor := tracker.Open("a")
+	first := cursor.Add(10, 100)
+	second := cursor.Add(20, 200)
+	// the source is read through before its requests are acknowledged
+	cursor.Done(25, 250)
+	expectSource(t, tracker, "a", Source{})
+	second(nil)
+	expectSource(t, tracker, "a", Source{})
+	first(nil)
+	expectSource(t, tracker, "a", Source{Lines: 25, Bytes: 250, Complete: true})
+}
+
+func TestCursorEmptySource(t *testing.T) {
+	tracker := NewTracker(&memoryStore{}, nil)
+	tracker.Open("a").Done(0, 0)
+	expectSource(t, tracker, "a", Source{Complete: true})
+}
+
+func TestCursorConcurrentAcks(t *testing.T) {
+	tracker := NewTracker(&memoryStore{}, nil)
+	cursor := tracker.Open("a")
+	var acks []func(error)
+	for n := int64(1); n <= 100; n++ {
+		acks = append(acks, cursor.Add(n, n*10))
+	}
+	cursor.Done(100, 1000)
+	wg := sync.WaitGroup{}
+	for n := len(acks) - 1; n >= 0; n-- {
+		wg.Add(1)
+		go func(ack func(error)) {
+			defer wg.Done()
+			ack(nil)
+		}(acks[n])
+	}
+	wg.Wait()
+	expectSource(t, tracker, "a", Source{Lines: 100, Bytes: 1000, Complete: true})
+}
+
+func TestTrackerResume(t *testing.T) {
+	checkpoint := New()
+	checkpoint.Sources["a"] = &Source{Lines: 10, Bytes: 100}
+	checkpoint.Sources["b"] = &Source{Lines: 5, Bytes: 50, Complete: true}
+	store := &memoryStore{}
+	tracker := NewTracker(store, checkpoint)
+	// the partial offsets are reported to resume from
+	expectSource(t, tracker, "a", Source{Lines: 10, Bytes: 100})
+	expectSource(t, tracker, "b", Source{Lines: 5, Bytes: 50, Complete: true})
+	expectSource(t, tracker, "c", Source{})
+	// offsets of resumed requests remain relative to the start of the source
+	cursor := tracker.Open("a")
+	ack := cursor.Add(20, 200)
+	ack(nil)
+	cursor.Done(25, 250)
+	expectSource(t, tracker, "a", Source{Lines: 25, Bytes: 250, Complete: true})
+	saved := store.saves[len(store.saves)-1]
+	if saved.Get("b") != *checkpoint.Sources["b"] {
+		t.Errorf("expected source `b` to be retained, got %+v", saved.Get("b"))
+	}
+	// the provided checkpoint is not modified
+	if checkpoint.Get("a") != (Source{Lines: 10, Bytes: 100}) {
+		t.Errorf("expected the resumed checkpoint to be unchanged, got %+v", checkpoint.Get("a"))
+	}
+}
+
+func TestTrackerSaveError(t *testing.T) {
+	store := &memoryStore{
+		err: errors.New("disk full"),
+	}
+	tracker := NewTracker(store, nil)
+	cursor := tracker.Open("a")
+	cursor.Add(10, 100)(nil)
+	if tracker.Err() != store.err {
+		t.Errorf("expected save error, got %v", tracker.Err())
+	}
+	// progress is still tracked in memory
+	expectSource(t, tracker, "a", Source{Lines: 10, Bytes: 100})
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deluge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileStore(filepath.Join(dir, "checkpoint.json"))
+	checkpoint, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checkpoint.IsEmpty() {
+		t.Errorf("expected an empty checkpoint, got %v", checkpoint.Sources)
+	}
+	tracker := NewTracker(store, checkpoint)
+	cursor := tracker.Open("a")
+	cursor.Add(10, 100)(nil)
+	if tracker.Err() != nil {
+		t.Fatal(tracker.Err())
+	}
+	checkpoint, err = store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint.Get("a") != (Source{Lines: 10, Bytes: 100}) {
+		t.Errorf("expected the saved progress to be loaded, got %+v", checkpoint.Get("a"))
+	}
+}
@@ -1,13 +1,37 @@
 package deluge
 
+import "time"
+
 // Client represents the elasticsearch client interface.
 type Client interface {
 	NewBulkRequest(string) BulkRequest
 	IndexExists(string) (bool, error)
 	DeleteIndex(string) error
-	CreateIndex(string, string) error
+	CreateIndex(string, string, string) error
 	PutMapping(string, string, string) error
 	EnableReplicas(string, int) error
+	PutSettings(string, string) error
+	Refresh(string) error
+	Count(string) (int64, error)
+	ForceMerge(string, int) error
+	WaitForGreen(string, time.Duration) error
+	SnapshotRepositoryExists(string) (bool, error)
+	CreateSnapshotRepository(string, string) error
+	CreateSnapshot(string, string, []string) (*SnapshotStatus, error)
 	SetReadOnly(string, bool) error
 	SetBlockWrite(string, bool) error
+	ListIndices(string) ([]string, error)
+	GetAliasIndices(string) ([]string, error)
+	UpdateAlias(string, string, []string) error
+}
+
+// TemplateClient represents an elasticsearch client that can install index
+// templates, component templates and index lifecycle policies. Component
+// templates and lifecycle policies are provided as JSON encoded request
+// bodies.
+type TemplateClient interface {
+	PutTemplate(*IndexTemplate) error
+	PutIndexTemplate(*IndexTemplate) error
+	PutComponentTemplate(string, string) error
+	PutLifecyclePolicy(string, string) error
 }
@@ -0,0 +1,76 @@
+package deluge
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	// CompressionAuto detects the compression of each source from its file
+	// extension or, failing that, from the magic bytes at its start.
+	CompressionAuto = "auto"
+	// the number of bytes required to detect the compression of a source
+	magicSize = 3
+)
+
+// compressions by file extension
+var compressionExtensions = map[string]string{
+	".gz":      "gzip",
+	".gzip":    "gzip",
+	".bz2":     "bzip2",
+	".bzip2":   "bzip2",
+	".zz":      "zlib",
+	".zlib":    "zlib",
+	".deflate": "flate",
+}
+
+// compressionFromMagic returns the compression identified by the magic bytes,
+// or an empty string if none is identified. Raw flate streams have no magic
+// bytes, so can only be detected by extension.
+func compressionFromMagic(magic []byte) string {
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return "gzip"
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return "bzip2"
+	case len(magic) >= 2 && magic[0]&0x0f == 8 && magic[0]>>4 <= 7 && magic[1]&0x20 == 0 &&
+		(uint16(magic[0])<<8|uint16(magic[1]))%31 == 0:
+		// deflate method with a window of at most 32K, no preset dictionary
+		// and a valid header checksum
+		return "zlib"
+	}
+	return ""
+}
+
+// detectCompression returns the compression of the named source and a reader
+// positioned at its start. Readers that can seek are rewound after reading the
+// magic bytes, otherwise the returned reader buffers them.
+func detectCompression(reader io.Reader, name string) (io.Reader, string, error) {
+	if compression, ok := compressionExtensions[strings.ToLower(path.Ext(name))]; ok {
+		return reader, compression, nil
+	}
+	// wrappers such as input.Reader implement io.Seeker regardless of the
+	// underlying reader, so check that seeking actually succeeds
+	start := int64(-1)
+	if seeker, ok := reader.(io.Seeker); ok {
+		if offset, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			start = offset
+		}
+	}
+	buffered := bufio.NewReader(reader)
+	magic, err := buffered.Peek(magicSize)
+	if err != nil && err != io.EOF {
+		return nil, "", err
+	}
+	compression := compressionFromMagic(magic)
+	if start < 0 {
+		return buffered, compression, nil
+	}
+	if _, err := reader.(io.Seeker).Seek(start, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	return reader, compression, nil
+}
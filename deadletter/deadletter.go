@@ -0,0 +1,15 @@
+package deadletter
+
+// Record represents a document that was rejected during an ingest.
+type Record struct {
+	Line       string `json:"line"`
+	Source     string `json:"source,omitempty"`
+	LineNumber int64  `json:"line_number"`
+	Error      string `json:"error"`
+}
+
+// Sink represents an output for rejected documents.
+type Sink interface {
+	Write(*Record) error
+	Flush() error
+}
@@ -0,0 +1,59 @@
+package deadletter
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// FileSink represents a sink that writes rejected documents to a local
+// newline delimited JSON file.
+type FileSink struct {
+	file   *os.File
+	writer *bufio.Writer
+	mu     *sync.Mutex
+}
+
+// NewFileSink creates the file and returns a new sink writing to it. If the
+// file already exists it is appended to.
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		mu:     &sync.Mutex{},
+	}, nil
+}
+
+// Write writes the record as a single line of JSON.
+func (s *FileSink) Write(record *Record) error {
+	bytes, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.writer.Write(append(bytes, '\n'))
+	return err
+}
+
+// Flush flushes any buffered records to the file.
+func (s *FileSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writer.Flush()
+}
+
+// Close flushes any buffered records and closes the file.
+func (s *FileSink) Close() error {
+	err := s.Flush()
+	if err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
@@ -12,3 +12,68 @@ type Document interface {
 
 // Constructor represents a constructor that instantiates a new deluge document.
 type Constructor func() (Document, error)
+
+// Operation represents the bulk operation used to ingest a document.
+type Operation string
+
+const (
+	// OperationIndex indexes the document, replacing any existing document.
+	OperationIndex Operation = "index"
+	// OperationCreate indexes the document, failing if it already exists.
+	OperationCreate Operation = "create"
+	// OperationUpdate partially updates an existing document with the source.
+	OperationUpdate Operation = "update"
+	// OperationUpsert partially updates the document with the source,
+	// indexing the source if the document does not exist.
+	OperationUpsert Operation = "upsert"
+	// OperationScript updates an existing document with the *Script returned
+	// as the source.
+	OperationScript Operation = "script"
+	// OperationDelete deletes the document. The source is ignored.
+	OperationDelete Operation = "delete"
+)
+
+// DocumentOperation represents a document that declares the bulk operation
+// used to ingest it. Documents that do not implement it, or that return an
+// empty operation, are indexed.
+type DocumentOperation interface {
+	GetOperation() (Operation, error)
+}
+
+// Script represents a script used to update a document.
+type Script struct {
+	Source string
+	Lang   string
+	Params map[string]interface{}
+}
+
+// Metadata represents the metadata set on the bulk action of a document.
+// Empty and nil fields are omitted from the action. Fields that are not
+// supported by the cluster version, or by the operation, are ignored.
+type Metadata struct {
+	// Routing routes the document to a shard by the value instead of the id.
+	Routing string
+	// Version is the version of the document, compared according to the
+	// VersionType, such as "external" or "external_gte".
+	Version     *int64
+	VersionType string
+	// IfSeqNo and IfPrimaryTerm only apply the operation if the document was
+	// last modified at the sequence number and primary term.
+	IfSeqNo       *int64
+	IfPrimaryTerm *int64
+	// Pipeline is the ingest pipeline used to preprocess indexed documents.
+	Pipeline string
+}
+
+// DocumentMetadata represents a document that declares metadata for its bulk
+// action.
+type DocumentMetadata interface {
+	GetMetadata() (*Metadata, error)
+}
+
+// DocumentIndex represents a document that declares the index it is ingested
+// into. Documents that do not implement it, or that return an empty index, are
+// ingested into the target index of the ingest.
+type DocumentIndex interface {
+	GetIndex() (string, error)
+}
@@ -0,0 +1,69 @@
+package deluge
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/unchartedsoftware/deluge/util"
+)
+
+// DryRunSummary represents the results of a dry run ingest.
+type DryRunSummary struct {
+	NumDocs      int64
+	NumErrors    int64
+	NumBulks     int64
+	TotalBytes   int64
+	MinBulkBytes int64
+	MaxBulkBytes int64
+	mutex        *sync.Mutex
+}
+
+func newDryRunSummary() *DryRunSummary {
+	return &DryRunSummary{
+		mutex: &sync.Mutex{},
+	}
+}
+
+func (s *DryRunSummary) addBulk(bytes, docs int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.NumBulks == 0 || bytes < s.MinBulkBytes {
+		s.MinBulkBytes = bytes
+	}
+	if bytes > s.MaxBulkBytes {
+		s.MaxBulkBytes = bytes
+	}
+	s.NumBulks++
+	s.NumDocs += docs
+	s.TotalBytes += bytes
+}
+
+// AvgBulkBytes returns the average estimated size of a bulk payload.
+func (s *DryRunSummary) AvgBulkBytes() int64 {
+	if s.NumBulks == 0 {
+		return 0
+	}
+	return s.TotalBytes / s.NumBulks
+}
+
+// ErrorRate returns the ratio of document errors to processed documents.
+func (s *DryRunSummary) ErrorRate() float64 {
+	total := s.NumDocs + s.NumErrors
+	if total == 0 {
+		return 0
+	}
+	return float64(s.NumErrors) / float64(total)
+}
+
+// String returns a string containing the summary information.
+func (s *DryRunSummary) String() string {
+	return fmt.Sprintf("Dry run parsed %d docs with %d errors (%.4f error rate) into %d bulk requests containing %s (min: %s, avg: %s, max: %s)",
+		s.NumDocs,
+		s.NumErrors,
+		s.ErrorRate(),
+		s.NumBulks,
+		util.FormatBytes(s.TotalBytes),
+		util.FormatBytes(s.MinBulkBytes),
+		util.FormatBytes(s.AvgBulkBytes()),
+		util.FormatBytes(s.MaxBulkBytes))
+}
@@ -0,0 +1,349 @@
+package estest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type bulkMeta struct {
+	Index         string `json:"_index"`
+	Type          string `json:"_type"`
+	ID            string `json:"_id"`
+	Routing       string `json:"routing"`
+	Version       *int64 `json:"version"`
+	VersionType   string `json:"version_type"`
+	IfSeqNo       *int64 `json:"if_seq_no"`
+	IfPrimaryTerm *int64 `json:"if_primary_term"`
+	Pipeline      string `json:"pipeline"`
+	// underscore prefixed metadata used prior to 6.x
+	LegacyRouting     string `json:"_routing"`
+	LegacyVersion     *int64 `json:"_version"`
+	LegacyVersionType string `json:"_version_type"`
+}
+
+// normalize replaces unset metadata with its underscore prefixed equivalent.
+func (m *bulkMeta) normalize() {
+	if m.Routing == "" {
+		m.Routing = m.LegacyRouting
+	}
+	if m.Version == nil {
+		m.Version = m.LegacyVersion
+	}
+	if m.VersionType == "" {
+		m.VersionType = m.LegacyVersionType
+	}
+}
+
+// checkVersion returns the reason the action conflicts with the version of the
+// existing document, or an empty string if it does not.
+func (m *bulkMeta) checkVersion(existing *document) string {
+	if m.IfSeqNo != nil {
+		if existing == nil {
+			return fmt.Sprintf("required seqNo [%d], but no document was found", *m.IfSeqNo)
+		}
+		if *m.IfSeqNo != existing.seqNo || (m.IfPrimaryTerm != nil && *m.IfPrimaryTerm != primaryTerm) {
+			return fmt.Sprintf("required seqNo [%d], primary term [%d]. current document has seqNo [%d] and primary term [%d]",
+				*m.IfSeqNo, derefOr(m.IfPrimaryTerm, primaryTerm), existing.seqNo, primaryTerm)
+		}
+	}
+	if m.Version == nil {
+		return ""
+	}
+	switch m.VersionType {
+	case "external", "external_gt":
+		if existing != nil && *m.Version <= existing.version {
+			return fmt.Sprintf("current version [%d] is higher or equal to the one provided [%d]",
+				existing.version, *m.Version)
+		}
+	case "external_gte":
+		if existing != nil && *m.Version < existing.version {
+			return fmt.Sprintf("current version [%d] is higher than the one provided [%d]",
+				existing.version, *m.Version)
+		}
+	default:
+		if existing == nil {
+			return fmt.Sprintf("required version [%d], but no document was found", *m.Version)
+		}
+		if *m.Version != existing.version {
+			return fmt.Sprintf("current version [%d] is different than the one provided [%d]",
+				existing.version, *m.Version)
+		}
+	}
+	return ""
+}
+
+// external returns whether the version of the action replaces the version of
+// the document.
+func (m *bulkMeta) external() bool {
+	return m.Version != nil && strings.HasPrefix(m.VersionType, "external")
+}
+
+func derefOr(value *int64, fallback int64) int64 {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
+
+type bulkAction struct {
+	op     string
+	meta   *bulkMeta
+	source json.RawMessage
+}
+
+func (s *Server) defaultType() string {
+	if s.typed() {
+		return "doc"
+	}
+	return "_doc"
+}
+
+// parseBulk parses the NDJSON body into actions.
+func parseBulk(body []byte) ([]*bulkAction, error) {
+	var actions []*bulkAction
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		header := make(map[string]*bulkMeta)
+		if err := json.Unmarshal(line, &header); err != nil {
+			return nil, fmt.Errorf("Malformed action/metadata line: %v", err)
+		}
+		if len(header) != 1 {
+			return nil, fmt.Errorf("Malformed action/metadata line, expected a single action")
+		}
+		action := &bulkAction{}
+		for op, meta := range header {
+			action.op = op
+			action.meta = meta
+		}
+		if action.meta == nil {
+			action.meta = &bulkMeta{}
+		}
+		action.meta.normalize()
+		switch action.op {
+		case "index", "create", "update":
+			if !scanner.Scan() {
+				return nil, fmt.Errorf("Validation Failed: 1: no requests added or missing source;")
+			}
+			action.source = append(json.RawMessage(nil), bytes.TrimSpace(scanner.Bytes())...)
+		case "delete":
+		default:
+			return nil, fmt.Errorf("Malformed action/metadata line, unknown action [%s]", action.op)
+		}
+		actions = append(actions, action)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
+func (s *Server) handleBulk(w http.ResponseWriter, r *http.Request, target string, body []byte) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		s.handleUnknown(w, r)
+		return
+	}
+	start := time.Now()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.rejectedBulks > 0 {
+		s.rejectedBulks--
+		writeError(w, http.StatusTooManyRequests, "es_rejected_execution_exception",
+			"rejected execution of bulk request")
+		return
+	}
+	actions, err := parseBulk(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "illegal_argument_exception", err.Error())
+		return
+	}
+	errors := false
+	items := make([]map[string]interface{}, len(actions))
+	for n, action := range actions {
+		res := s.applyBulkAction(target, action)
+		if _, ok := res["error"]; ok {
+			errors = true
+		}
+		items[n] = map[string]interface{}{
+			action.op: res,
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"took":   time.Since(start).Nanoseconds() / int64(time.Millisecond),
+		"errors": errors,
+		"items":  items,
+	})
+}
+
+// applyBulkAction applies a single bulk action and returns its response item.
+// Callers must hold the mutex.
+func (s *Server) applyBulkAction(target string, action *bulkAction) map[string]interface{} {
+	name := action.meta.Index
+	if name == "" {
+		name = target
+	}
+	typ := action.meta.Type
+	if typ == "" {
+		typ = s.defaultType()
+	}
+	id := action.meta.ID
+	if id == "" && action.op != "delete" && action.op != "update" {
+		s.numIDs++
+		id = "auto-" + strconv.Itoa(s.numIDs)
+	}
+	res := map[string]interface{}{
+		"_index": name,
+		"_id":    id,
+	}
+	if s.typed() {
+		res["_type"] = typ
+	}
+	fail := func(status int, errType string, reason string) map[string]interface{} {
+		res["status"] = status
+		res["error"] = &errorCause{
+			Type:   errType,
+			Reason: reason,
+			Index:  name,
+		}
+		return res
+	}
+	if name == "" {
+		return fail(http.StatusBadRequest, "action_request_validation_exception",
+			"Validation Failed: 1: index is missing;")
+	}
+	if s.rejectedItems > 0 {
+		s.rejectedItems--
+		return fail(http.StatusTooManyRequests, "es_rejected_execution_exception",
+			"rejected execution of bulk item")
+	}
+	if s.failer != nil {
+		if e := s.failer(name, id, action.source); e != nil {
+			return fail(e.Status, e.Type, e.Reason)
+		}
+	}
+	idx, ok := s.indices[name]
+	if ok && idx.blocked() {
+		return fail(http.StatusForbidden, "cluster_block_exception",
+			fmt.Sprintf("index [%s] blocked by: [FORBIDDEN/8/index write (api)];", name))
+	}
+	if !ok {
+		if action.op == "delete" || action.op == "update" {
+			return fail(http.StatusNotFound, "index_not_found_exception",
+				fmt.Sprintf("no such index [%s]", name))
+		}
+		// indices are created automatically on write
+		idx = s.createIndex(name)
+	}
+	existing := idx.docs[id]
+	version := int64(1)
+	if existing != nil {
+		version = existing.version + 1
+	}
+	if conflict := action.meta.checkVersion(existing); conflict != "" {
+		return fail(http.StatusConflict, "version_conflict_engine_exception",
+			fmt.Sprintf("[%s]: version conflict, %s", id, conflict))
+	}
+	if action.meta.external() {
+		version = *action.meta.Version
+	}
+	switch action.op {
+	case "index", "create":
+		if action.op == "create" && existing != nil {
+			return fail(http.StatusConflict, "version_conflict_engine_exception",
+				fmt.Sprintf("[%s]: version conflict, document already exists", id))
+		}
+		if !isObject(action.source) {
+			return fail(http.StatusBadRequest, "mapper_parsing_exception",
+				"failed to parse")
+		}
+		if s.droppedItems > 0 {
+			// acknowledge the document without storing it
+			s.droppedItems--
+			res["result"] = "created"
+			res["status"] = http.StatusCreated
+			return res
+		}
+		idx.put(id, &document{
+			typ:      typ,
+			source:   action.source,
+			version:  version,
+			routing:  action.meta.Routing,
+			pipeline: action.meta.Pipeline,
+		})
+		res["result"] = "created"
+		res["status"] = http.StatusCreated
+		if existing != nil {
+			res["result"] = "updated"
+			res["status"] = http.StatusOK
+		}
+	case "update":
+		update := struct {
+			Doc         map[string]interface{} `json:"doc"`
+			Script      map[string]interface{} `json:"script"`
+			Upsert      map[string]interface{} `json:"upsert"`
+			DocAsUpsert bool                   `json:"doc_as_upsert"`
+		}{}
+		if err := json.Unmarshal(action.source, &update); err != nil || (update.Doc == nil && update.Script == nil) {
+			return fail(http.StatusBadRequest, "action_request_validation_exception",
+				"Validation Failed: 1: script or doc is missing;")
+		}
+		var doc map[string]interface{}
+		if existing != nil {
+			doc = make(map[string]interface{})
+			json.Unmarshal(existing.source, &doc)
+			// scripts are not executed, the document is left unchanged
+			mergeMaps(doc, update.Doc)
+			res["result"] = "updated"
+			res["status"] = http.StatusOK
+		} else {
+			switch {
+			case update.Upsert != nil:
+				doc = update.Upsert
+			case update.DocAsUpsert:
+				doc = update.Doc
+			default:
+				return fail(http.StatusNotFound, "document_missing_exception",
+					fmt.Sprintf("[%s][%s]: document missing", typ, id))
+			}
+			res["result"] = "created"
+			res["status"] = http.StatusCreated
+		}
+		source, _ := json.Marshal(doc)
+		idx.put(id, &document{
+			typ:     typ,
+			source:  source,
+			version: version,
+			routing: action.meta.Routing,
+		})
+	case "delete":
+		if existing == nil {
+			res["result"] = "not_found"
+			res["status"] = http.StatusNotFound
+			return res
+		}
+		idx.remove(id)
+		res["result"] = "deleted"
+		res["status"] = http.StatusOK
+	}
+	res["_version"] = version
+	if s.major >= 6 {
+		res["_seq_no"] = idx.seqNo - 1
+		res["_primary_term"] = primaryTerm
+	}
+	return res
+}
+
+func isObject(source json.RawMessage) bool {
+	var obj map[string]interface{}
+	return json.Unmarshal(source, &obj) == nil && obj != nil
+}
@@ -0,0 +1,908 @@
+// Package clienttest provides the test suite shared by the elasticsearch
+// client adapters. Each adapter runs the suite against an estest server,
+// describing the features supported by its cluster version.
+package clienttest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/unchartedsoftware/deluge"
+	"github.com/unchartedsoftware/deluge/backoff"
+	"github.com/unchartedsoftware/deluge/elastic/estest"
+	es "github.com/unchartedsoftware/deluge/input/elastic"
+)
+
+const (
+	testIndex   = "test"
+	testMapping = `{"properties":{"name":{"type":"keyword"}}}`
+)
+
+// Templates represents the index templates supported by a client.
+type Templates int
+
+const (
+	// TemplatesNone indicates that the client does not install templates.
+	TemplatesNone Templates = iota
+	// TemplatesLegacy indicates that the client only installs legacy index
+	// templates with a single index pattern.
+	TemplatesLegacy
+	// TemplatesComposable indicates that the client installs legacy,
+	// composable and component templates, as well as lifecycle policies.
+	TemplatesComposable
+)
+
+// Client represents the client under test.
+type Client interface {
+	deluge.Client
+	es.Client
+	SetBulkBackoff(*backoff.Backoff)
+}
+
+// Config describes the client under test and the features of the cluster it
+// targets.
+type Config struct {
+	// NewClient returns a new client for the server at the provided URL.
+	NewClient func(url string) (Client, error)
+	// Options configures every server, ex. with the version of the cluster.
+	Options []estest.OptionFunc
+	// Type is the document type, or empty for typeless clusters.
+	Type string
+	// Shards is the number of shards the client creates indices with, or 0 if
+	// the client uses the default of the cluster.
+	Shards int
+	// Pipelines indicates that the client sends ingest pipelines.
+	Pipelines bool
+	// SeqNo indicates that the client sends sequence number based
+	// optimistic concurrency control.
+	SeqNo bool
+	// Templates is the template support of the client.
+	Templates Templates
+}
+
+type suite struct {
+	config *Config
+	// the backoff used by every bulk request
+	backoff *backoff.Backoff
+}
+
+// Run runs the test suite against the client described by the config.
+func Run(t *testing.T, config *Config) {
+	b, err := backoff.New(
+		backoff.SetInitialDelay(time.Millisecond),
+		backoff.SetMaxDelay(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &suite{
+		config:  config,
+		backoff: b,
+	}
+	tests := []struct {
+		name string
+		test func(*testing.T)
+	}{
+		{"IndexManagement", s.testIndexManagement},
+		{"Aliases", s.testAliases},
+		{"BulkRequest", s.testBulkRequest},
+		{"BulkRequestSerializedSources", s.testBulkRequestSerializedSources},
+		{"BulkRequestFailedItems", s.testBulkRequestFailedItems},
+		{"BulkRequestRetriesRejectedItems", s.testBulkRequestRetriesRejectedItems},
+		{"BulkRequestExhaustsRetries", s.testBulkRequestExhaustsRetries},
+		{"BulkRequestRejected", s.testBulkRequestRejected},
+		{"GetIndexSummary", s.testGetIndexSummary},
+		{"IndexReader", s.testIndexReader},
+		{"BulkRequestOperations", s.testBulkRequestOperations},
+		{"BulkRequestMetadata", s.testBulkRequestMetadata},
+		{"BulkRequestIndex", s.testBulkRequestIndex},
+		{"Templates", s.testTemplates},
+		{"IndexSettings", s.testIndexSettings},
+		{"IndexMaintenance", s.testIndexMaintenance},
+		{"WaitForGreenTimeout", s.testWaitForGreenTimeout},
+		{"Count", s.testCount},
+		{"Snapshot", s.testSnapshot},
+	}
+	for _, test := range tests {
+		t.Run(test.name, test.test)
+	}
+}
+
+func (s *suite) newTestClient(t *testing.T, options ...estest.OptionFunc) (*estest.Server, Client) {
+	server := estest.NewServer(append(append([]estest.OptionFunc{}, s.config.Options...), options...)...)
+	client, err := s.config.NewClient(server.URL)
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	client.SetBulkBackoff(s.backoff)
+	return server, client
+}
+
+// mapping returns the provided mapping nested under the document type, as
+// reported by typed clusters.
+func (s *suite) mapping(mapping string) string {
+	if s.config.Type == "" {
+		return mapping
+	}
+	return fmt.Sprintf(`{"%s":%s}`, s.config.Type, mapping)
+}
+
+func seed(t *testing.T, server *estest.Server, index string, numDocs int) {
+	for n := 0; n < numDocs; n++ {
+		err := server.PutDocument(index, fmt.Sprintf("%d", n), map[string]interface{}{
+			"name": fmt.Sprintf("doc-%d", n),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func int64Ptr(value int64) *int64 {
+	return &value
+}
+
+func (s *suite) testIndexManagement(t *testing.T) {
+	server, client := s.newTestClient(t)
+	defer server.Close()
+	exists, err := client.IndexExists(testIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatalf("expected index `%s` to not exist", testIndex)
+	}
+	mapping := s.mapping(testMapping)
+	if err := client.CreateIndex(testIndex, mapping, ""); err != nil {
+		t.Fatal(err)
+	}
+	exists, err = client.IndexExists(testIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatalf("expected index `%s` to exist", testIndex)
+	}
+	if server.Mapping(testIndex) != mapping {
+		t.Errorf("expected mapping %s, got %s", mapping, server.Mapping(testIndex))
+	}
+	if s.config.Shards > 0 && server.Setting(testIndex, "number_of_shards") != fmt.Sprint(s.config.Shards) {
+		t.Errorf("expected %d shards, got %s", s.config.Shards, server.Setting(testIndex, "number_of_shards"))
+	}
+	if server.Setting(testIndex, "number_of_replicas") != "0" {
+		t.Errorf("expected 0 replicas, got %s", server.Setting(testIndex, "number_of_replicas"))
+	}
+	if err := client.CreateIndex(testIndex, mapping, ""); err == nil {
+		t.Error("expected an error when creating an existing index")
+	}
+	if err := client.PutMapping(testIndex, s.config.Type, `{"properties":{"age":{"type":"long"}}}`); err != nil {
+		t.Fatal(err)
+	}
+	expected := s.mapping(`{"properties":{"age":{"type":"long"},"name":{"type":"keyword"}}}`)
+	if server.Mapping(testIndex) != expected {
+		t.Errorf("expected mapping %s, got %s", expected, server.Mapping(testIndex))
+	}
+	if err := client.EnableReplicas(testIndex, 2); err != nil {
+		t.Fatal(err)
+	}
+	if server.Setting(testIndex, "number_of_replicas") != "2" {
+		t.Errorf("expected 2 replicas, got %s", server.Setting(testIndex, "number_of_replicas"))
+	}
+	if err := client.SetReadOnly(testIndex, true); err != nil {
+		t.Fatal(err)
+	}
+	if server.Setting(testIndex, "blocks.read_only") != "true" {
+		t.Errorf("expected index to be read only, got %s", server.Setting(testIndex, "blocks.read_only"))
+	}
+	if err := client.SetBlockWrite(testIndex, true); err != nil {
+		t.Fatal(err)
+	}
+	if server.Setting(testIndex, "blocks.write") != "true" {
+		t.Errorf("expected index to block writes, got %s", server.Setting(testIndex, "blocks.write"))
+	}
+	if err := client.DeleteIndex(testIndex); err != nil {
+		t.Fatal(err)
+	}
+	if server.IndexExists(testIndex) {
+		t.Fatalf("expected index `%s` to be deleted", testIndex)
+	}
+	if err := client.DeleteIndex(testIndex); err == nil {
+		t.Error("expected an error when deleting a missing index")
+	}
+}
+
+func (s *suite) testAliases(t *testing.T) {
+	server, client := s.newTestClient(t)
+	defer server.Close()
+	server.CreateIndex("events-1")
+	server.CreateIndex("events-2")
+	server.CreateIndex("other")
+	indices, err := client.ListIndices("events-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indices) != 2 {
+		t.Errorf("expected 2 indices, got %v", indices)
+	}
+	indices, err = client.GetAliasIndices("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indices) != 0 {
+		t.Errorf("expected missing alias to have no indices, got %v", indices)
+	}
+	if err := client.UpdateAlias("events", "events-1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.UpdateAlias("events", "events-2", []string{"events-1"}); err != nil {
+		t.Fatal(err)
+	}
+	indices, err = client.GetAliasIndices("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indices) != 1 || indices[0] != "events-2" {
+		t.Errorf("expected alias to point to `events-2`, got %v", indices)
+	}
+}
+
+func (s *suite) testBulkRequest(t *testing.T) {
+	server, client := s.newTestClient(t)
+	defer server.Close()
+	bulk := client.NewBulkRequest(testIndex)
+	for n := 0; n < 3; n++ {
+		bulk.Add(s.config.Type, fmt.Sprintf("%d", n), map[string]interface{}{
+			"name": fmt.Sprintf("doc-%d", n),
+		})
+	}
+	if bulk.Size() != 3 {
+		t.Errorf("expected 3 documents, got %d", bulk.Size())
+	}
+	if bulk.EstimatedSizeInBytes() <= 0 {
+		t.Errorf("expected a positive size estimate, got %d", bulk.EstimatedSizeInBytes())
+	}
+	if _, err := bulk.Send(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(bulk.Failed()) != 0 {
+		t.Errorf("expected no failed documents, got %v", bulk.Failed())
+	}
+	if server.NumDocs(testIndex) != 3 {
+		t.Errorf("expected 3 indexed documents, got %d", server.NumDocs(testIndex))
+	}
+	source, ok := server.Document(testIndex, "1")
+	if !ok || string(source) != `{"name":"doc-1"}` {
+		t.Errorf("unexpected document source %s", source)
+	}
+}
+
+func (s *suite) testBulkRequestSerializedSources(t *testing.T) {
+	server, client := s.newTestClient(t)
+	defer server.Close()
+	source := `{"name":"doc-1"}`
+	bulk := client.NewBulkRequest(testIndex)
+	bulk.Add(s.config.Type, "0", `{"name":"doc-0"}`)
+	bulk.Add(s.config.Type, "1", &source)
+	bulk.Add(s.config.Type, "2", json.RawMessage(`{"name":"doc-2"}`))
+	if _, err := bulk.Send(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(bulk.Failed()) != 0 {
+		t.Errorf("expected no failed documents, got %v", bulk.Failed())
+	}
+	// pre-serialized sources are written as-is rather than as JSON strings
+	for n := 0; n < 3; n++ {
+		id := fmt.Sprintf("%d", n)
+		expected := fmt.Sprintf(`{"name":"doc-%d"}`, n)
+		source, ok := server.Document(testIndex, id)
+		if !ok || string(source) != expected {
+			t.Errorf("expected document `%s` source %s, got %s", id, expected, source)
+		}
+	}
+}
+
+func (s *suite) testBulkRequestFailedItems(t *testing.T) {
+	server, client := s.newTestClient(t, estest.SetItemFailer(func(index string, id string, source json.RawMessage) *estest.ItemError {
+		if id == "1" {
+			return &estest.ItemError{
+				Status: http.StatusBadRequest,
+				Type:   "mapper_parsing_exception",
+				Reason: "failed to parse field [name]",
+			}
+		}
+		return nil
+	}))
+	defer server.Close()
+	bulk := client.NewBulkRequest(testIndex)
+	for n := 0; n < 3; n++ {
+		bulk.Add(s.config.Type, fmt.Sprintf("%d", n), map[string]interface{}{
+			"name": fmt.Sprintf("doc-%d", n),
+		})
+	}
+	if _, err := bulk.Send(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	failed := bulk.Failed()
+	if len(failed) != 1 {
+		t.Fatalf("expected 1 failed document, got %d", len(failed))
+	}
+	if failed[0].Item != 1 || failed[0].ID != "1" || failed[0].Status != http.StatusBadRequest ||
+		failed[0].Type != "mapper_parsing_exception" {
+		t.Errorf("unexpected failed document %v", failed[0])
+	}
+	if server.NumDocs(testIndex) != 2 {
+		t.Errorf("expected 2 indexed documents, got %d", server.NumDocs(testIndex))
+	}
+}
+
+func (s *suite) testBulkRequestRetriesRejectedItems(t *testing.T) {
+	server, client := s.newTestClient(t, estest.SetRejectedItems(4))
+	defer server.Close()
+	bulk := client.NewBulkRequest(testIndex)
+	for n := 0; n < 3; n++ {
+		bulk.Add(s.config.Type, fmt.Sprintf("%d", n), map[string]interface{}{
+			"name": fmt.Sprintf("doc-%d", n),
+		})
+	}
+	if _, err := bulk.Send(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(bulk.Failed()) != 0 {
+		t.Errorf("expected no failed documents, got %v", bulk.Failed())
+	}
+	if server.NumDocs(testIndex) != 3 {
+		t.Errorf("expected 3 indexed documents, got %d", server.NumDocs(testIndex))
+	}
+	if server.NumRequests("POST", "/_bulk") != 3 {
+		t.Errorf("expected 3 bulk requests, got %d", server.NumRequests("POST", "/_bulk"))
+	}
+}
+
+func (s *suite) testBulkRequestExhaustsRetries(t *testing.T) {
+	server, client := s.newTestClient(t, estest.SetRejectedItems(1000))
+	defer server.Close()
+	bulk := client.NewBulkRequest(testIndex)
+	bulk.Add(s.config.Type, "0", map[string]interface{}{
+		"name": "doc-0",
+	})
+	if _, err := bulk.Send(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	failed := bulk.Failed()
+	if len(failed) != 1 || failed[0].Status != http.StatusTooManyRequests {
+		t.Fatalf("expected 1 rejected document, got %v", failed)
+	}
+	if server.NumRequests("POST", "/_bulk") != s.backoff.MaxAttempts() {
+		t.Errorf("expected %d bulk requests, got %d",
+			s.backoff.MaxAttempts(),
+			server.NumRequests("POST", "/_bulk"))
+	}
+}
+
+func (s *suite) testBulkRequestRejected(t *testing.T) {
+	server, client := s.newTestClient(t, estest.SetRejectedBulks(1))
+	defer server.Close()
+	bulk := client.NewBulkRequest(testIndex)
+	bulk.Add(s.config.Type, "0", map[string]interface{}{
+		"name": "doc-0",
+	})
+	if _, err := bulk.Send(context.Background()); err == nil {
+		t.Error("expected an error for a rejected bulk request")
+	}
+}
+
+func (s *suite) testGetIndexSummary(t *testing.T) {
+	server, client := s.newTestClient(t)
+	defer server.Close()
+	seed(t, server, testIndex, 25)
+	summary, err := client.GetIndexSummary(testIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.NumDocs() != 25 {
+		t.Errorf("expected 25 documents, got %d", summary.NumDocs())
+	}
+	if summary.ByteSize() == 0 {
+		t.Error("expected a non-zero byte size")
+	}
+	if _, err := client.GetIndexSummary("missing"); err == nil {
+		t.Error("expected an error for a missing index")
+	}
+}
+
+func (s *suite) testIndexReader(t *testing.T) {
+	server, client := s.newTestClient(t)
+	defer server.Close()
+	seed(t, server, testIndex, 25)
+	reader, err := client.GetIndexReader(testIndex, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]bool)
+	numPages := 0
+	for {
+		page, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		numPages++
+		scanner := bufio.NewScanner(page)
+		for scanner.Scan() {
+			hit := struct {
+				ID     string                 `json:"_id"`
+				Source map[string]interface{} `json:"_source"`
+			}{}
+			if err := json.Unmarshal(scanner.Bytes(), &hit); err != nil {
+				t.Fatal(err)
+			}
+			if hit.Source["name"] != "doc-"+hit.ID {
+				t.Errorf("unexpected hit %s", scanner.Text())
+			}
+			ids[hit.ID] = true
+		}
+	}
+	if len(ids) != 25 {
+		t.Errorf("expected 25 documents, got %d", len(ids))
+	}
+	if numPages != 3 {
+		t.Errorf("expected 3 pages, got %d", numPages)
+	}
+}
+
+func (s *suite) testBulkRequestOperations(t *testing.T) {
+	server, client := s.newTestClient(t)
+	defer server.Close()
+	seed(t, server, testIndex, 4)
+	bulk := client.NewBulkRequest(testIndex)
+	bulk.AddItem(&deluge.BulkItem{
+		Operation: deluge.OperationCreate,
+		Type:      s.config.Type,
+		ID:        "0",
+		Source:    map[string]interface{}{"name": "created"},
+	})
+	bulk.AddItem(&deluge.BulkItem{
+		Operation: deluge.OperationCreate,
+		Type:      s.config.Type,
+		ID:        "4",
+		Source:    map[string]interface{}{"name": "created"},
+	})
+	bulk.AddItem(&deluge.BulkItem{
+		Operation: deluge.OperationUpdate,
+		Type:      s.config.Type,
+		ID:        "1",
+		Source:    `{"age":1}`,
+	})
+	bulk.AddItem(&deluge.BulkItem{
+		Operation: deluge.OperationUpsert,
+		Type:      s.config.Type,
+		ID:        "5",
+		Source:    map[string]interface{}{"name": "upserted"},
+	})
+	bulk.AddItem(&deluge.BulkItem{
+		Operation: deluge.OperationScript,
+		Type:      s.config.Type,
+		ID:        "2",
+		Source: &deluge.Script{
+			Source: "ctx._source.age += params.age",
+			Params: map[string]interface{}{"age": 1},
+		},
+	})
+	bulk.AddItem(&deluge.BulkItem{
+		Operation: deluge.OperationDelete,
+		Type:      s.config.Type,
+		ID:        "3",
+	})
+	if bulk.Size() != 6 {
+		t.Errorf("expected 6 documents, got %d", bulk.Size())
+	}
+	if _, err := bulk.Send(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	failed := bulk.Failed()
+	if len(failed) != 1 || failed[0].Item != 0 || failed[0].Status != http.StatusConflict {
+		t.Fatalf("expected only the existing document to conflict, got %v", failed)
+	}
+	expected := map[string]string{
+		"0": `{"name":"doc-0"}`,
+		"1": `{"age":1,"name":"doc-1"}`,
+		"2": `{"name":"doc-2"}`,
+		"4": `{"name":"created"}`,
+		"5": `{"name":"upserted"}`,
+	}
+	for id, src := range expected {
+		source, ok := server.Document(testIndex, id)
+		if !ok || string(source) != src {
+			t.Errorf("expected document `%s` source %s, got %s", id, src, source)
+		}
+	}
+	if _, ok := server.Document(testIndex, "3"); ok {
+		t.Error("expected document `3` to be deleted")
+	}
+}
+
+func (s *suite) testBulkRequestMetadata(t *testing.T) {
+	server, client := s.newTestClient(t)
+	defer server.Close()
+	seed(t, server, testIndex, 2)
+	source := map[string]interface{}{"name": "meta"}
+	bulk := client.NewBulkRequest(testIndex)
+	bulk.AddItem(&deluge.BulkItem{
+		Type:   s.config.Type,
+		ID:     "2",
+		Source: source,
+		Metadata: &deluge.Metadata{
+			Routing:  "shard-a",
+			Pipeline: "enrich",
+		},
+	})
+	bulk.AddItem(&deluge.BulkItem{
+		Type:   s.config.Type,
+		ID:     "0",
+		Source: source,
+		Metadata: &deluge.Metadata{
+			Version:     int64Ptr(5),
+			VersionType: "external",
+		},
+	})
+	bulk.AddItem(&deluge.BulkItem{
+		Type:   s.config.Type,
+		ID:     "1",
+		Source: source,
+		Metadata: &deluge.Metadata{
+			Version:     int64Ptr(1),
+			VersionType: "external",
+		},
+	})
+	conflicts := []int{2}
+	if s.config.SeqNo {
+		bulk.AddItem(&deluge.BulkItem{
+			Operation: deluge.OperationDelete,
+			Type:      s.config.Type,
+			ID:        "1",
+			Metadata: &deluge.Metadata{
+				IfSeqNo:       int64Ptr(1),
+				IfPrimaryTerm: int64Ptr(1),
+			},
+		})
+		bulk.AddItem(&deluge.BulkItem{
+			Type:   s.config.Type,
+			ID:     "3",
+			Source: source,
+			Metadata: &deluge.Metadata{
+				IfSeqNo:       int64Ptr(0),
+				IfPrimaryTerm: int64Ptr(1),
+			},
+		})
+		conflicts = append(conflicts, 4)
+	}
+	if _, err := bulk.Send(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	failed := bulk.Failed()
+	if len(failed) != len(conflicts) {
+		t.Fatalf("expected %d version conflicts, got %v", len(conflicts), failed)
+	}
+	for n, item := range conflicts {
+		if failed[n].Item != item || failed[n].Status != http.StatusConflict {
+			t.Errorf("expected item %d to conflict, got %v", item, failed[n])
+		}
+	}
+	pipeline := ""
+	if s.config.Pipelines {
+		pipeline = "enrich"
+	}
+	meta, ok := server.DocumentMetadata(testIndex, "2")
+	if !ok || meta.Routing != "shard-a" || meta.Pipeline != pipeline {
+		t.Errorf("unexpected metadata %v", meta)
+	}
+	meta, ok = server.DocumentMetadata(testIndex, "0")
+	if !ok || meta.Version != 5 {
+		t.Errorf("expected external version 5, got %v", meta)
+	}
+	if _, ok := server.Document(testIndex, "1"); s.config.SeqNo && ok {
+		t.Error("expected document `1` to be deleted")
+	}
+}
+
+func (s *suite) testBulkRequestIndex(t *testing.T) {
+	server, client := s.newTestClient(t)
+	defer server.Close()
+	bulk := client.NewBulkRequest(testIndex)
+	for n := 0; n < 4; n++ {
+		index := ""
+		if n%2 == 1 {
+			index = "other"
+		}
+		bulk.AddItem(&deluge.BulkItem{
+			Index:  index,
+			Type:   s.config.Type,
+			ID:     fmt.Sprintf("%d", n),
+			Source: map[string]interface{}{"name": fmt.Sprintf("doc-%d", n)},
+		})
+	}
+	if _, err := bulk.Send(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(bulk.Failed()) != 0 {
+		t.Errorf("expected no failed documents, got %v", bulk.Failed())
+	}
+	if server.NumDocs(testIndex) != 2 {
+		t.Errorf("expected 2 documents in `%s`, got %d", testIndex, server.NumDocs(testIndex))
+	}
+	if _, ok := server.Document("other", "1"); !ok {
+		t.Error("expected document `1` to be indexed into `other`")
+	}
+}
+
+func (s *suite) testTemplates(t *testing.T) {
+	if s.config.Templates == TemplatesNone {
+		t.Skip("templates are not supported")
+	}
+	server, c := s.newTestClient(t)
+	defer server.Close()
+	client, ok := c.(deluge.TemplateClient)
+	if !ok {
+		t.Fatal("expected the client to support templates")
+	}
+	if s.config.Templates == TemplatesLegacy {
+		s.testLegacyTemplates(t, server, c, client)
+		return
+	}
+	mapping := s.mapping(testMapping)
+	err := client.PutComponentTemplate("shards", `{"template":{"settings":{"number_of_shards":3}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.PutLifecyclePolicy("rollover", `{"policy":{"phases":{"hot":{"actions":{"rollover":{"max_size":"50gb"}}}}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.LifecyclePolicy("rollover"); !ok {
+		t.Error("expected lifecycle policy `rollover` to exist")
+	}
+	err = client.PutIndexTemplate(&deluge.IndexTemplate{
+		Name:       "logs",
+		Patterns:   []string{"logs-*"},
+		Priority:   10,
+		ComposedOf: []string{"shards"},
+		Settings:   `{"index":{"lifecycle":{"name":"rollover"}}}`,
+		Mapping:    mapping,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.PutTemplate(&deluge.IndexTemplate{
+		Name:     "legacy",
+		Patterns: []string{"logs-*", "metrics-*"},
+		Settings: `{"number_of_shards":5}`,
+		Mapping:  s.mapping(`{"properties":{"value":{"type":"long"}}}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.PutIndexTemplate(&deluge.IndexTemplate{
+		Name:       "missing",
+		Patterns:   []string{"missing-*"},
+		ComposedOf: []string{"missing"},
+	})
+	if err == nil {
+		t.Error("expected an error for a template composed of a missing component template")
+	}
+	// indices created by the cluster receive the templates
+	bulk := c.NewBulkRequest("logs-1")
+	bulk.Add(s.config.Type, "0", map[string]interface{}{"name": "doc-0"})
+	bulk.AddItem(&deluge.BulkItem{
+		Index:  "metrics-1",
+		Type:   s.config.Type,
+		ID:     "0",
+		Source: map[string]interface{}{"value": 1},
+	})
+	if _, err := bulk.Send(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if server.Mapping("logs-1") != mapping {
+		t.Errorf("expected mapping %s, got %s", mapping, server.Mapping("logs-1"))
+	}
+	if server.Setting("logs-1", "number_of_shards") != "3" {
+		t.Errorf("expected 3 shards, got %s", server.Setting("logs-1", "number_of_shards"))
+	}
+	if server.Setting("logs-1", "lifecycle.name") != "rollover" {
+		t.Errorf("expected lifecycle policy `rollover`, got %s", server.Setting("logs-1", "lifecycle.name"))
+	}
+	if server.Setting("metrics-1", "number_of_shards") != "5" {
+		t.Errorf("expected 5 shards, got %s", server.Setting("metrics-1", "number_of_shards"))
+	}
+}
+
+func (s *suite) testLegacyTemplates(t *testing.T, server *estest.Server, c Client, client deluge.TemplateClient) {
+	mapping := s.mapping(testMapping)
+	err := client.PutTemplate(&deluge.IndexTemplate{
+		Name:     "logs",
+		Patterns: []string{"logs-*"},
+		Settings: `{"number_of_shards":3}`,
+		Mapping:  mapping,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.PutTemplate(&deluge.IndexTemplate{
+		Name:     "multiple",
+		Patterns: []string{"logs-*", "metrics-*"},
+	})
+	if err == nil {
+		t.Error("expected an error for a template with multiple patterns")
+	}
+	if err := client.PutIndexTemplate(&deluge.IndexTemplate{Name: "logs"}); err == nil {
+		t.Error("expected an error for a composable template")
+	}
+	if err := client.PutLifecyclePolicy("rollover", `{"policy":{}}`); err == nil {
+		t.Error("expected an error for a lifecycle policy")
+	}
+	// indices created by the cluster receive the template
+	bulk := c.NewBulkRequest("logs-1")
+	bulk.Add(s.config.Type, "0", map[string]interface{}{"name": "doc-0"})
+	if _, err := bulk.Send(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if server.Mapping("logs-1") != mapping {
+		t.Errorf("expected mapping %s, got %s", mapping, server.Mapping("logs-1"))
+	}
+	if server.Setting("logs-1", "number_of_shards") != "3" {
+		t.Errorf("expected 3 shards, got %s", server.Setting("logs-1", "number_of_shards"))
+	}
+}
+
+func (s *suite) testIndexSettings(t *testing.T) {
+	server, client := s.newTestClient(t)
+	defer server.Close()
+	mapping := s.mapping(testMapping)
+	err := client.CreateIndex(testIndex, mapping, `{"index":{"number_of_shards":4,"refresh_interval":"30s"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"number_of_shards":   "4",
+		"number_of_replicas": "0",
+		"refresh_interval":   "30s",
+	}
+	for key, value := range expected {
+		if server.Setting(testIndex, key) != value {
+			t.Errorf("expected setting `%s` to be %s, got %s", key, value, server.Setting(testIndex, key))
+		}
+	}
+	if err := client.PutSettings(testIndex, `{"index.refresh_interval":null,"index.translog.durability":"async"}`); err != nil {
+		t.Fatal(err)
+	}
+	if server.Setting(testIndex, "refresh_interval") != "" {
+		t.Errorf("expected refresh interval to be reset, got %s", server.Setting(testIndex, "refresh_interval"))
+	}
+	if server.Setting(testIndex, "translog.durability") != "async" {
+		t.Errorf("expected async translog durability, got %s", server.Setting(testIndex, "translog.durability"))
+	}
+	if err := client.CreateIndex("invalid", mapping, `{"number_of_shards":`); err == nil {
+		t.Error("expected an error for malformed settings")
+	}
+}
+
+func (s *suite) testIndexMaintenance(t *testing.T) {
+	server, client := s.newTestClient(t)
+	defer server.Close()
+	if err := client.CreateIndex(testIndex, s.mapping(testMapping), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Refresh(testIndex); err != nil {
+		t.Fatal(err)
+	}
+	if server.NumRequests("POST", "/"+testIndex+"/_refresh") != 1 {
+		t.Error("expected the index to be refreshed")
+	}
+	if err := client.ForceMerge(testIndex, 1); err != nil {
+		t.Fatal(err)
+	}
+	if server.MaxNumSegments(testIndex) != 1 {
+		t.Errorf("expected the index to be merged to 1 segment, got %d", server.MaxNumSegments(testIndex))
+	}
+	if err := client.WaitForGreen(testIndex, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// merges are blocked on read-only indices
+	if err := client.SetReadOnly(testIndex, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ForceMerge(testIndex, 1); err == nil {
+		t.Error("expected an error force merging a read-only index")
+	}
+}
+
+func (s *suite) testWaitForGreenTimeout(t *testing.T) {
+	server, client := s.newTestClient(t, estest.SetHealth("yellow"))
+	defer server.Close()
+	if err := client.CreateIndex(testIndex, s.mapping(testMapping), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.WaitForGreen(testIndex, 10*time.Millisecond); err == nil {
+		t.Error("expected an error waiting for a yellow index")
+	}
+}
+
+func (s *suite) testCount(t *testing.T) {
+	server, client := s.newTestClient(t)
+	defer server.Close()
+	for n := 0; n < 3; n++ {
+		if err := server.PutDocument(testIndex, fmt.Sprintf("%d", n), map[string]interface{}{"n": n}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count, err := client.Count(testIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 documents, got %d", count)
+	}
+	if _, err := client.Count("missing"); err == nil {
+		t.Error("expected an error for a missing index")
+	}
+}
+
+func (s *suite) testSnapshot(t *testing.T) {
+	server, client := s.newTestClient(t)
+	defer server.Close()
+	if err := client.CreateIndex(testIndex, s.mapping(testMapping), ""); err != nil {
+		t.Fatal(err)
+	}
+	exists, err := client.SnapshotRepositoryExists("backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("expected the repository to not exist")
+	}
+	if err := client.CreateSnapshotRepository("backups", "/mnt/backups"); err != nil {
+		t.Fatal(err)
+	}
+	exists, err = client.SnapshotRepositoryExists("backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatal("expected the repository to exist")
+	}
+	body, _ := server.SnapshotRepository("backups")
+	repo := struct {
+		Type     string            `json:"type"`
+		Settings map[string]string `json:"settings"`
+	}{}
+	if err := json.Unmarshal(body, &repo); err != nil {
+		t.Fatal(err)
+	}
+	if repo.Type != "fs" || repo.Settings["location"] != "/mnt/backups" {
+		t.Errorf("expected an fs repository at /mnt/backups, got %s", body)
+	}
+	status, err := client.CreateSnapshot("backups", "snap-1", []string{testIndex})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Succeeded() || status.Snapshot != "snap-1" || status.FailedShards != 0 ||
+		fmt.Sprint(status.TotalShards) != server.Setting(testIndex, "number_of_shards") {
+		t.Errorf("unexpected snapshot status: %v", status)
+	}
+	if indices := server.SnapshotIndices("backups", "snap-1"); len(indices) != 1 || indices[0] != testIndex {
+		t.Errorf("expected the snapshot to contain `%s`, got %v", testIndex, indices)
+	}
+	if _, err := client.CreateSnapshot("backups", "snap-1", []string{testIndex}); err == nil {
+		t.Error("expected an error for a duplicate snapshot name")
+	}
+	if _, err := client.CreateSnapshot("missing", "snap-1", []string{testIndex}); err == nil {
+		t.Error("expected an error for a missing repository")
+	}
+}
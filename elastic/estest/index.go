@@ -0,0 +1,642 @@
+package estest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// primaryTerm is the primary term of every document, as primaries never fail.
+const primaryTerm = 1
+
+type document struct {
+	typ      string
+	source   json.RawMessage
+	version  int64
+	seqNo    int64
+	routing  string
+	pipeline string
+}
+
+type index struct {
+	name     string
+	settings map[string]string
+	mappings map[string]interface{}
+	aliases  map[string]bool
+	docs     map[string]*document
+	// the sequence number of the next operation
+	seqNo int64
+	// the ids of the documents in insertion order
+	ids []string
+	// the max_num_segments of the last force merge
+	segments int
+}
+
+func newIndex(name string) *index {
+	return &index{
+		name: name,
+		settings: map[string]string{
+			"index.number_of_shards":   "1",
+			"index.number_of_replicas": "1",
+		},
+		mappings: make(map[string]interface{}),
+		aliases:  make(map[string]bool),
+		docs:     make(map[string]*document),
+	}
+}
+
+func (i *index) put(id string, doc *document) {
+	doc.seqNo = i.seqNo
+	i.seqNo++
+	if _, ok := i.docs[id]; !ok {
+		i.ids = append(i.ids, id)
+	}
+	i.docs[id] = doc
+}
+
+func (i *index) remove(id string) {
+	if _, ok := i.docs[id]; !ok {
+		return
+	}
+	delete(i.docs, id)
+	i.seqNo++
+	for n, existing := range i.ids {
+		if existing == id {
+			i.ids = append(i.ids[:n], i.ids[n+1:]...)
+			break
+		}
+	}
+}
+
+func (i *index) byteSize() int64 {
+	size := int64(0)
+	for _, doc := range i.docs {
+		size += int64(len(doc.source))
+	}
+	return size
+}
+
+// blocked returns whether writes to the index are blocked.
+func (i *index) blocked() bool {
+	return i.settings["index.blocks.write"] == "true" ||
+		i.settings["index.blocks.read_only"] == "true"
+}
+
+// flattenSettings flattens nested settings into dot-separated keys prefixed
+// with "index.", ex. {"blocks":{"write":true}} becomes "index.blocks.write".
+func flattenSettings(prefix string, value interface{}, settings map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, sub := range v {
+			flattenSettings(prefix+key+".", sub, settings)
+		}
+	case nil:
+		// a null value resets the setting to its default
+		delete(settings, normalizeSetting(strings.TrimSuffix(prefix, ".")))
+	case string:
+		settings[normalizeSetting(strings.TrimSuffix(prefix, "."))] = v
+	default:
+		settings[normalizeSetting(strings.TrimSuffix(prefix, "."))] = fmt.Sprintf("%v", v)
+	}
+}
+
+func normalizeSetting(key string) string {
+	if strings.HasPrefix(key, "index.") {
+		return key
+	}
+	return "index." + key
+}
+
+// nestSettings reverses flattenSettings.
+func nestSettings(settings map[string]string) map[string]interface{} {
+	nested := make(map[string]interface{})
+	for key, value := range settings {
+		parts := strings.Split(key, ".")
+		current := nested
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := current[part].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				current[part] = next
+			}
+			current = next
+		}
+		current[parts[len(parts)-1]] = value
+	}
+	return nested
+}
+
+// mergeMaps deeply merges src into dst.
+func mergeMaps(dst map[string]interface{}, src map[string]interface{}) {
+	for key, value := range src {
+		srcMap, srcOK := value.(map[string]interface{})
+		dstMap, dstOK := dst[key].(map[string]interface{})
+		if srcOK && dstOK {
+			mergeMaps(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request, target string, body []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch r.Method {
+	case http.MethodHead:
+		if len(s.resolve(target)) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPut, http.MethodPost:
+		if _, ok := s.indices[target]; ok {
+			writeError(w, http.StatusBadRequest, "resource_already_exists_exception",
+				fmt.Sprintf("index [%s] already exists", target))
+			return
+		}
+		req := struct {
+			Settings map[string]interface{} `json:"settings"`
+			Mappings map[string]interface{} `json:"mappings"`
+		}{}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &req); err != nil {
+				writeError(w, http.StatusBadRequest, "parse_exception", err.Error())
+				return
+			}
+		}
+		idx := s.createIndex(target)
+		flattenSettings("", req.Settings, idx.settings)
+		mergeMaps(idx.mappings, req.Mappings)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"acknowledged":        true,
+			"shards_acknowledged": true,
+			"index":               target,
+		})
+	case http.MethodDelete:
+		names := s.resolve(target)
+		if len(names) == 0 {
+			writeError(w, http.StatusNotFound, "index_not_found_exception",
+				fmt.Sprintf("no such index [%s]", target))
+			return
+		}
+		for _, name := range names {
+			delete(s.indices, name)
+		}
+		writeAcknowledged(w)
+	case http.MethodGet:
+		names := s.resolve(target)
+		if len(names) == 0 {
+			writeError(w, http.StatusNotFound, "index_not_found_exception",
+				fmt.Sprintf("no such index [%s]", target))
+			return
+		}
+		res := make(map[string]interface{})
+		for _, name := range names {
+			idx := s.indices[name]
+			res[name] = map[string]interface{}{
+				"aliases":  idx.aliasesBody(),
+				"mappings": idx.mappings,
+				"settings": nestSettings(idx.settings),
+			}
+		}
+		writeJSON(w, http.StatusOK, res)
+	default:
+		s.handleUnknown(w, r)
+	}
+}
+
+func (s *Server) handleMapping(w http.ResponseWriter, r *http.Request, target string, typ string, body []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	names := s.resolve(target)
+	if len(names) == 0 {
+		writeError(w, http.StatusNotFound, "index_not_found_exception",
+			fmt.Sprintf("no such index [%s]", target))
+		return
+	}
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		mapping := make(map[string]interface{})
+		if err := json.Unmarshal(body, &mapping); err != nil {
+			writeError(w, http.StatusBadRequest, "mapper_parsing_exception", err.Error())
+			return
+		}
+		if typ != "" {
+			mapping = map[string]interface{}{
+				typ: mapping,
+			}
+		}
+		for _, name := range names {
+			mergeMaps(s.indices[name].mappings, mapping)
+		}
+		writeAcknowledged(w)
+	case http.MethodGet:
+		res := make(map[string]interface{})
+		for _, name := range names {
+			res[name] = map[string]interface{}{
+				"mappings": s.indices[name].mappings,
+			}
+		}
+		writeJSON(w, http.StatusOK, res)
+	default:
+		s.handleUnknown(w, r)
+	}
+}
+
+func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request, target string, body []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	names := s.resolve(target)
+	if len(names) == 0 && target != "_all" {
+		writeError(w, http.StatusNotFound, "index_not_found_exception",
+			fmt.Sprintf("no such index [%s]", target))
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		settings := make(map[string]interface{})
+		if err := json.Unmarshal(body, &settings); err != nil {
+			writeError(w, http.StatusBadRequest, "parse_exception", err.Error())
+			return
+		}
+		// settings may optionally be wrapped in a "settings" object
+		if inner, ok := settings["settings"].(map[string]interface{}); ok {
+			settings = inner
+		}
+		for _, name := range names {
+			flattenSettings("", settings, s.indices[name].settings)
+		}
+		writeAcknowledged(w)
+	case http.MethodGet:
+		res := make(map[string]interface{})
+		for _, name := range names {
+			res[name] = map[string]interface{}{
+				"settings": nestSettings(s.indices[name].settings),
+			}
+		}
+		writeJSON(w, http.StatusOK, res)
+	default:
+		s.handleUnknown(w, r)
+	}
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request, target string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	names := s.resolve(target)
+	if len(names) == 0 {
+		writeError(w, http.StatusNotFound, "index_not_found_exception",
+			fmt.Sprintf("no such index [%s]", target))
+		return
+	}
+	indices := make(map[string]interface{})
+	for _, name := range names {
+		idx := s.indices[name]
+		stats := map[string]interface{}{
+			"docs": map[string]interface{}{
+				"count":   len(idx.docs),
+				"deleted": 0,
+			},
+			"store": map[string]interface{}{
+				"size_in_bytes": idx.byteSize(),
+			},
+		}
+		indices[name] = map[string]interface{}{
+			"primaries": stats,
+			"total":     stats,
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"_shards": map[string]int{
+			"total":      len(names),
+			"successful": len(names),
+			"failed":     0,
+		},
+		"indices": indices,
+	})
+}
+
+func (s *Server) handleForceMerge(w http.ResponseWriter, r *http.Request, target string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	names := s.resolve(target)
+	if len(names) == 0 {
+		writeError(w, http.StatusNotFound, "index_not_found_exception",
+			fmt.Sprintf("no such index [%s]", target))
+		return
+	}
+	segments := 0
+	if param := r.URL.Query().Get("max_num_segments"); param != "" {
+		var err error
+		segments, err = strconv.Atoi(param)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "illegal_argument_exception",
+				fmt.Sprintf("failed to parse max_num_segments [%s]", param))
+			return
+		}
+	}
+	for _, name := range names {
+		// unlike the write block, the read-only block also blocks merges
+		if s.indices[name].settings["index.blocks.read_only"] == "true" {
+			writeError(w, http.StatusForbidden, "cluster_block_exception",
+				fmt.Sprintf("index [%s] blocked by: [FORBIDDEN/5/index read-only (api)];", name))
+			return
+		}
+	}
+	for _, name := range names {
+		s.indices[name].segments = segments
+	}
+	s.handleShards(w)
+}
+
+func (i *index) aliasesBody() map[string]interface{} {
+	aliases := make(map[string]interface{})
+	for alias := range i.aliases {
+		aliases[alias] = map[string]interface{}{}
+	}
+	return aliases
+}
+
+type aliasAction struct {
+	Index   string   `json:"index"`
+	Indices []string `json:"indices"`
+	Alias   string   `json:"alias"`
+	Aliases []string `json:"aliases"`
+}
+
+func (s *Server) handleAliases(w http.ResponseWriter, r *http.Request, target string, segs []string, body []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if target == "" {
+			target = "_all"
+		}
+		name := ""
+		if len(segs) > 0 {
+			name = segs[0]
+		}
+		res := make(map[string]interface{})
+		for _, idx := range s.resolve(target) {
+			aliases := make(map[string]interface{})
+			for alias := range s.indices[idx].aliases {
+				if name == "" || matchPattern(name, alias) {
+					aliases[alias] = map[string]interface{}{}
+				}
+			}
+			if name != "" && len(aliases) == 0 {
+				continue
+			}
+			res[idx] = map[string]interface{}{
+				"aliases": aliases,
+			}
+		}
+		if name != "" && len(res) == 0 {
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{
+				"error":  fmt.Sprintf("alias [%s] missing", name),
+				"status": http.StatusNotFound,
+			})
+			return
+		}
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	case http.MethodPost:
+		req := struct {
+			Actions []map[string]*aliasAction `json:"actions"`
+		}{}
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "parse_exception", err.Error())
+			return
+		}
+		// validate every action before applying any, the update is atomic
+		for _, actions := range req.Actions {
+			for typ, action := range actions {
+				if typ != "add" && typ != "remove" {
+					writeError(w, http.StatusBadRequest, "illegal_argument_exception",
+						fmt.Sprintf("unsupported alias action [%s]", typ))
+					return
+				}
+				for _, name := range action.indices() {
+					if _, ok := s.indices[name]; !ok {
+						writeError(w, http.StatusNotFound, "index_not_found_exception",
+							fmt.Sprintf("no such index [%s]", name))
+						return
+					}
+				}
+			}
+		}
+		for _, actions := range req.Actions {
+			for typ, action := range actions {
+				for _, name := range action.indices() {
+					for _, alias := range action.aliases() {
+						if typ == "add" {
+							s.indices[name].aliases[alias] = true
+						} else {
+							delete(s.indices[name].aliases, alias)
+						}
+					}
+				}
+			}
+		}
+		writeAcknowledged(w)
+	default:
+		s.handleUnknown(w, r)
+	}
+}
+
+func (a *aliasAction) indices() []string {
+	if a.Index != "" {
+		return append([]string{a.Index}, a.Indices...)
+	}
+	return a.Indices
+}
+
+func (a *aliasAction) aliases() []string {
+	if a.Alias != "" {
+		return append([]string{a.Alias}, a.Aliases...)
+	}
+	return a.Aliases
+}
+
+// Indices returns the sorted names of all indices.
+func (s *Server) Indices() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.resolve("_all")
+}
+
+// IndexExists returns whether or not the specified index exists.
+func (s *Server) IndexExists(name string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, ok := s.indices[name]
+	return ok
+}
+
+// CreateIndex creates an empty index.
+func (s *Server) CreateIndex(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.indices[name]; !ok {
+		s.createIndex(name)
+	}
+}
+
+// PutDocument stores the document in the specified index, creating the index
+// if it does not exist.
+func (s *Server) PutDocument(name string, id string, source interface{}) error {
+	bytes, err := json.Marshal(source)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	idx, ok := s.indices[name]
+	if !ok {
+		idx = s.createIndex(name)
+	}
+	version := int64(1)
+	if existing, ok := idx.docs[id]; ok {
+		version = existing.version + 1
+	}
+	idx.put(id, &document{
+		typ:     s.defaultType(),
+		source:  bytes,
+		version: version,
+	})
+	return nil
+}
+
+// Document returns the source of the document in the specified index.
+func (s *Server) Document(name string, id string) (json.RawMessage, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	idx, ok := s.indices[name]
+	if !ok {
+		return nil, false
+	}
+	doc, ok := idx.docs[id]
+	if !ok {
+		return nil, false
+	}
+	return doc.source, true
+}
+
+// Metadata represents the metadata of a stored document.
+type Metadata struct {
+	Routing     string
+	Version     int64
+	SeqNo       int64
+	PrimaryTerm int64
+	// Pipeline is the ingest pipeline requested when indexing the document.
+	// Pipelines are not executed.
+	Pipeline string
+}
+
+// DocumentMetadata returns the metadata of the document in the specified
+// index.
+func (s *Server) DocumentMetadata(name string, id string) (*Metadata, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	idx, ok := s.indices[name]
+	if !ok {
+		return nil, false
+	}
+	doc, ok := idx.docs[id]
+	if !ok {
+		return nil, false
+	}
+	return &Metadata{
+		Routing:     doc.routing,
+		Version:     doc.version,
+		SeqNo:       doc.seqNo,
+		PrimaryTerm: primaryTerm,
+		Pipeline:    doc.pipeline,
+	}, true
+}
+
+// DocumentIDs returns the ids of all documents in the specified index, in
+// insertion order.
+func (s *Server) DocumentIDs(name string) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	idx, ok := s.indices[name]
+	if !ok {
+		return nil
+	}
+	ids := make([]string, len(idx.ids))
+	copy(ids, idx.ids)
+	return ids
+}
+
+// NumDocs returns the number of documents in the specified index.
+func (s *Server) NumDocs(name string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	idx, ok := s.indices[name]
+	if !ok {
+		return 0
+	}
+	return len(idx.docs)
+}
+
+// Mapping returns the JSON encoded mappings of the specified index.
+func (s *Server) Mapping(name string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	idx, ok := s.indices[name]
+	if !ok {
+		return ""
+	}
+	bytes, _ := json.Marshal(idx.mappings)
+	return string(bytes)
+}
+
+// Setting returns the value of the specified setting of the index, ex.
+// "index.number_of_replicas". Keys without the "index." prefix are accepted.
+func (s *Server) Setting(name string, key string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	idx, ok := s.indices[name]
+	if !ok {
+		return ""
+	}
+	return idx.settings[normalizeSetting(key)]
+}
+
+// MaxNumSegments returns the max_num_segments of the last force merge of the
+// index, or 0 if the index was never force merged or was merged without it.
+func (s *Server) MaxNumSegments(name string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	idx, ok := s.indices[name]
+	if !ok {
+		return 0
+	}
+	return idx.segments
+}
+
+// AliasIndices returns the sorted names of the indices the alias points to.
+func (s *Server) AliasIndices(alias string) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var names []string
+	for name, idx := range s.indices {
+		if idx.aliases[alias] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,259 @@
+package estest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultSearchSize = 10
+)
+
+type hit struct {
+	Index  string          `json:"_index"`
+	Type   string          `json:"_type,omitempty"`
+	ID     string          `json:"_id"`
+	Score  float64         `json:"_score"`
+	Source json.RawMessage `json:"_source"`
+}
+
+type scroll struct {
+	hits  []*hit
+	size  int
+	total int
+}
+
+// snapshot returns the hits of every document in the specified indices.
+// Callers must hold the mutex.
+func (s *Server) snapshot(names []string) []*hit {
+	var hits []*hit
+	for _, name := range names {
+		idx := s.indices[name]
+		for _, id := range idx.ids {
+			doc := idx.docs[id]
+			h := &hit{
+				Index:  name,
+				ID:     id,
+				Score:  1,
+				Source: doc.source,
+			}
+			if s.typed() {
+				h.Type = doc.typ
+			}
+			hits = append(hits, h)
+		}
+	}
+	return hits
+}
+
+// total returns the total hits in the format of the server version.
+func (s *Server) total(total int) interface{} {
+	if s.typed() {
+		return total
+	}
+	return map[string]interface{}{
+		"value":    total,
+		"relation": "eq",
+	}
+}
+
+func (s *Server) searchResponse(scrollID string, total int, hits []*hit) map[string]interface{} {
+	if hits == nil {
+		hits = []*hit{}
+	}
+	res := map[string]interface{}{
+		"took":      0,
+		"timed_out": false,
+		"_shards": map[string]int{
+			"total":      1,
+			"successful": 1,
+			"failed":     0,
+		},
+		"hits": map[string]interface{}{
+			"total":     s.total(total),
+			"max_score": 1,
+			"hits":      hits,
+		},
+	}
+	if scrollID != "" {
+		res["_scroll_id"] = scrollID
+	}
+	return res
+}
+
+func (s *Server) handleCount(w http.ResponseWriter, r *http.Request, target string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		s.handleUnknown(w, r)
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	names := s.resolve(target)
+	if len(names) == 0 && target != "_all" {
+		writeError(w, http.StatusNotFound, "index_not_found_exception",
+			fmt.Sprintf("no such index [%s]", target))
+		return
+	}
+	count := 0
+	for _, name := range names {
+		count += len(s.indices[name].docs)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count": count,
+		"_shards": map[string]int{
+			"total":      len(names),
+			"successful": len(names),
+			"failed":     0,
+		},
+	})
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request, target string, body []byte) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		s.handleUnknown(w, r)
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	names := s.resolve(target)
+	if len(names) == 0 && target != "_all" {
+		writeError(w, http.StatusNotFound, "index_not_found_exception",
+			fmt.Sprintf("no such index [%s]", target))
+		return
+	}
+	query := r.URL.Query()
+	size := defaultSearchSize
+	req := struct {
+		Size *int `json:"size"`
+	}{}
+	if len(body) > 0 && json.Unmarshal(body, &req) == nil && req.Size != nil {
+		size = *req.Size
+	}
+	if param := query.Get("size"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "illegal_argument_exception",
+				fmt.Sprintf("Failed to parse int parameter [size] with value [%s]", param))
+			return
+		}
+		size = n
+	}
+	hits := s.snapshot(names)
+	total := len(hits)
+	if query.Get("scroll") == "" {
+		if len(hits) > size {
+			hits = hits[:size]
+		}
+		writeJSON(w, http.StatusOK, s.searchResponse("", total, hits))
+		return
+	}
+	s.numScrolls++
+	scrollID := "scroll-" + strconv.Itoa(s.numScrolls)
+	cursor := &scroll{
+		hits:  hits,
+		size:  size,
+		total: total,
+	}
+	s.scrolls[scrollID] = cursor
+	if query.Get("search_type") == "scan" {
+		// legacy scans return no hits in the initial response
+		writeJSON(w, http.StatusOK, s.searchResponse(scrollID, total, nil))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.searchResponse(scrollID, total, cursor.next()))
+}
+
+func (c *scroll) next() []*hit {
+	n := c.size
+	if n > len(c.hits) {
+		n = len(c.hits)
+	}
+	hits := c.hits[:n]
+	c.hits = c.hits[n:]
+	return hits
+}
+
+// scrollIDs extracts the scroll ids from the request. They may be provided in
+// the path, as a query parameter, as a JSON body or as a plain text body.
+func scrollIDs(r *http.Request, segs []string, body []byte) []string {
+	if len(segs) > 0 {
+		return strings.Split(segs[0], ",")
+	}
+	if param := r.URL.Query().Get("scroll_id"); param != "" {
+		return strings.Split(param, ",")
+	}
+	trimmed := strings.TrimSpace(string(body))
+	if trimmed == "" {
+		return nil
+	}
+	if !strings.HasPrefix(trimmed, "{") {
+		var id string
+		if json.Unmarshal(body, &id) == nil {
+			return []string{id}
+		}
+		return strings.Split(trimmed, ",")
+	}
+	req := struct {
+		ScrollID json.RawMessage `json:"scroll_id"`
+	}{}
+	if json.Unmarshal(body, &req) != nil {
+		return nil
+	}
+	var id string
+	if json.Unmarshal(req.ScrollID, &id) == nil {
+		return []string{id}
+	}
+	var ids []string
+	json.Unmarshal(req.ScrollID, &ids)
+	return ids
+}
+
+func (s *Server) handleScroll(w http.ResponseWriter, r *http.Request, segs []string, body []byte) {
+	ids := scrollIDs(r, segs, body)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch r.Method {
+	case http.MethodGet, http.MethodPost:
+		if len(ids) != 1 {
+			writeError(w, http.StatusBadRequest, "action_request_validation_exception",
+				"Validation Failed: 1: scrollId is missing;")
+			return
+		}
+		cursor, ok := s.scrolls[ids[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "search_context_missing_exception",
+				fmt.Sprintf("No search context found for id [%s]", ids[0]))
+			return
+		}
+		writeJSON(w, http.StatusOK, s.searchResponse(ids[0], cursor.total, cursor.next()))
+	case http.MethodDelete:
+		freed := 0
+		for _, id := range ids {
+			if id == "_all" {
+				freed += len(s.scrolls)
+				s.scrolls = make(map[string]*scroll)
+				break
+			}
+			if _, ok := s.scrolls[id]; ok {
+				delete(s.scrolls, id)
+				freed++
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"succeeded": true,
+			"num_freed": freed,
+		})
+	default:
+		s.handleUnknown(w, r)
+	}
+}
+
+// NumScrolls returns the number of scrolls that have not been cleared.
+func (s *Server) NumScrolls() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.scrolls)
+}
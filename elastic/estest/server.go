@@ -0,0 +1,497 @@
+// Package estest provides an in-memory fake elasticsearch server for testing
+// ingests without a running cluster.
+package estest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultVersion = "7.10.2"
+	clusterName    = "estest"
+)
+
+// ItemError represents an error returned for an individual bulk item.
+type ItemError struct {
+	Status int
+	Type   string
+	Reason string
+}
+
+// ItemFailer is a function that decides whether an individual bulk item
+// fails. It returns nil for items that should succeed.
+type ItemFailer func(index string, id string, source json.RawMessage) *ItemError
+
+// OptionFunc is a function that configures a Server. It is used in NewServer.
+type OptionFunc func(*Server)
+
+// SetVersion sets the elasticsearch version reported by the server. The
+// version determines whether types are reported and how search hit totals are
+// formatted. The default is 7.10.2.
+func SetVersion(version string) OptionFunc {
+	return func(s *Server) {
+		s.version = version
+	}
+}
+
+// SetDistribution sets the distribution reported by the server, ex.
+// "opensearch". OpenSearch was forked from elasticsearch 7.10.2, and the
+// server emulates that version regardless of the version reported.
+func SetDistribution(distribution string) OptionFunc {
+	return func(s *Server) {
+		s.distribution = distribution
+	}
+}
+
+// SetLatency sets the latency added to every request.
+func SetLatency(latency time.Duration) OptionFunc {
+	return func(s *Server) {
+		s.latency = latency
+	}
+}
+
+// SetRejectedBulks sets the number of bulk requests that are rejected in
+// their entirety with a 429 status code before requests are accepted.
+func SetRejectedBulks(numBulks int) OptionFunc {
+	return func(s *Server) {
+		s.rejectedBulks = numBulks
+	}
+}
+
+// SetRejectedItems sets the number of bulk items that are rejected with a
+// 429 status code before items are accepted.
+func SetRejectedItems(numItems int) OptionFunc {
+	return func(s *Server) {
+		s.rejectedItems = numItems
+	}
+}
+
+// SetDroppedItems sets the number of indexed bulk items that are acknowledged
+// as created without being stored, emulating silently lost documents.
+func SetDroppedItems(numItems int) OptionFunc {
+	return func(s *Server) {
+		s.droppedItems = numItems
+	}
+}
+
+// SetItemFailer sets the function used to fail individual bulk items.
+func SetItemFailer(failer ItemFailer) OptionFunc {
+	return func(s *Server) {
+		s.failer = failer
+	}
+}
+
+// SetHealth sets the health status reported for the cluster and every index,
+// ex. "yellow". Requests waiting for a better status time out. The default is
+// green.
+func SetHealth(status string) OptionFunc {
+	return func(s *Server) {
+		s.health = status
+	}
+}
+
+// Server represents an in-memory elasticsearch server. It emulates the index,
+// mapping, settings, alias, template, snapshot, bulk, stats and scroll
+// endpoints well enough for the deluge clients.
+type Server struct {
+	*httptest.Server
+	version       string
+	distribution  string
+	major         int
+	latency       time.Duration
+	rejectedBulks int
+	rejectedItems int
+	droppedItems  int
+	failer        ItemFailer
+	health        string
+	snapshotState string
+	mutex         sync.Mutex
+	indices       map[string]*index
+	scrolls       map[string]*scroll
+	numScrolls    int
+	numIDs        int
+	requests      []string
+
+	// legacy, composable and component templates, and lifecycle policies
+	templates          map[string]*template
+	indexTemplates     map[string]*template
+	componentTemplates map[string]*template
+	policies           map[string]*template
+
+	// snapshot repositories and their snapshots
+	repositories map[string]*repository
+}
+
+// NewServer starts and returns a new server. The caller should call Close
+// when finished, to shut it down.
+func NewServer(options ...OptionFunc) *Server {
+	s := &Server{
+		version:            defaultVersion,
+		health:             "green",
+		snapshotState:      "SUCCESS",
+		indices:            make(map[string]*index),
+		templates:          make(map[string]*template),
+		indexTemplates:     make(map[string]*template),
+		componentTemplates: make(map[string]*template),
+		policies:           make(map[string]*template),
+		repositories:       make(map[string]*repository),
+		scrolls:            make(map[string]*scroll),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	s.major, _ = strconv.Atoi(strings.SplitN(s.version, ".", 2)[0])
+	if s.distribution == "opensearch" {
+		s.major = 7
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Requests returns the method and path of every request received by the
+// server, ex. "POST /index/_bulk".
+func (s *Server) Requests() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	requests := make([]string, len(s.requests))
+	copy(requests, s.requests)
+	return requests
+}
+
+// NumRequests returns the number of requests received with the provided
+// method and path suffix, ex. ("POST", "/_bulk").
+func (s *Server) NumRequests(method string, suffix string) int {
+	count := 0
+	for _, req := range s.Requests() {
+		parts := strings.SplitN(req, " ", 2)
+		if parts[0] == method && strings.HasSuffix(parts[1], suffix) {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Server) typed() bool {
+	return s.major < 7
+}
+
+type errorBody struct {
+	Error  *errorCause `json:"error"`
+	Status int         `json:"status"`
+}
+
+type errorCause struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+	Index  string `json:"index,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, typ string, reason string) {
+	writeJSON(w, status, &errorBody{
+		Error: &errorCause{
+			Type:   typ,
+			Reason: reason,
+		},
+		Status: status,
+	})
+}
+
+func writeAcknowledged(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"acknowledged": true,
+	})
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	latency := s.latency
+	s.mutex.Unlock()
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "parse_exception", err.Error())
+		return
+	}
+	// the v8 client refuses to talk to a server without the product header
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	s.route(w, r, body)
+}
+
+func (s *Server) route(w http.ResponseWriter, r *http.Request, body []byte) {
+	path := strings.Trim(r.URL.Path, "/")
+	var segs []string
+	if path != "" {
+		segs = strings.Split(path, "/")
+	}
+	if len(segs) == 0 {
+		s.handleRoot(w, r)
+		return
+	}
+	switch segs[0] {
+	case "_bulk":
+		s.handleBulk(w, r, "", body)
+		return
+	case "_aliases", "_alias":
+		s.handleAliases(w, r, "", segs[1:], body)
+		return
+	case "_search":
+		if len(segs) > 1 && segs[1] == "scroll" {
+			s.handleScroll(w, r, segs[2:], body)
+			return
+		}
+		s.handleSearch(w, r, "_all", body)
+		return
+	case "_cat":
+		if len(segs) > 1 && segs[1] == "indices" {
+			s.handleCatIndices(w, r, segs[2:])
+			return
+		}
+	case "_nodes":
+		s.handleNodes(w, r)
+		return
+	case "_cluster":
+		if len(segs) > 1 && segs[1] == "health" {
+			s.handleHealth(w, r)
+			return
+		}
+	case "_refresh", "_flush":
+		s.handleShards(w)
+		return
+	case "_template":
+		s.handleTemplate(w, r, s.templates, segs[1:], body, s.parseTemplate)
+		return
+	case "_index_template":
+		// composable templates were introduced in 7.8
+		if s.major >= 7 {
+			s.handleTemplate(w, r, s.indexTemplates, segs[1:], body, s.parseIndexTemplate)
+			return
+		}
+	case "_component_template":
+		if s.major >= 7 {
+			s.handleTemplate(w, r, s.componentTemplates, segs[1:], body, s.parseComponentTemplate)
+			return
+		}
+	case "_snapshot":
+		s.handleSnapshot(w, r, segs[1:], body)
+		return
+	case "_ilm":
+		// lifecycle policies were introduced in 6.6
+		if s.major >= 6 && len(segs) > 1 && segs[1] == "policy" {
+			s.handleTemplate(w, r, s.policies, segs[2:], body, s.parsePolicy)
+			return
+		}
+	}
+	if strings.HasPrefix(segs[0], "_") && segs[0] != "_all" {
+		s.handleUnknown(w, r)
+		return
+	}
+	target := segs[0]
+	if len(segs) == 1 {
+		s.handleIndex(w, r, target, body)
+		return
+	}
+	switch segs[1] {
+	case "_bulk":
+		s.handleBulk(w, r, target, body)
+		return
+	case "_mapping", "_mappings":
+		typ := ""
+		if len(segs) > 2 {
+			typ = segs[2]
+		}
+		s.handleMapping(w, r, target, typ, body)
+		return
+	case "_settings":
+		s.handleSettings(w, r, target, body)
+		return
+	case "_stats":
+		s.handleStats(w, r, target)
+		return
+	case "_search":
+		s.handleSearch(w, r, target, body)
+		return
+	case "_count":
+		s.handleCount(w, r, target)
+		return
+	case "_alias", "_aliases":
+		s.handleAliases(w, r, target, segs[2:], body)
+		return
+	case "_refresh", "_flush":
+		s.handleShards(w)
+		return
+	case "_forcemerge":
+		s.handleForceMerge(w, r, target)
+		return
+	}
+	if len(segs) == 3 {
+		// legacy typed endpoints, ex. /index/type/_bulk
+		switch segs[2] {
+		case "_bulk":
+			s.handleBulk(w, r, target, body)
+			return
+		case "_mapping", "_mappings":
+			s.handleMapping(w, r, target, segs[1], body)
+			return
+		case "_search":
+			s.handleSearch(w, r, target, body)
+			return
+		}
+	}
+	s.handleUnknown(w, r)
+}
+
+func (s *Server) handleUnknown(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusBadRequest, "illegal_argument_exception",
+		fmt.Sprintf("no handler found for uri [%s] and method [%s]", r.URL.Path, r.Method))
+}
+
+func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	version := map[string]interface{}{
+		"number": s.version,
+	}
+	if s.distribution != "" {
+		version["distribution"] = s.distribution
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name":         clusterName,
+		"cluster_name": clusterName,
+		"version":      version,
+		"tagline":      "You Know, for Search",
+	})
+}
+
+func (s *Server) handleNodes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"cluster_name": clusterName,
+		"nodes": map[string]interface{}{
+			"node-0": map[string]interface{}{
+				"name": clusterName,
+				"http": map[string]interface{}{
+					"publish_address": s.Listener.Addr().String(),
+				},
+			},
+		},
+	})
+}
+
+func (s *Server) handleShards(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"_shards": map[string]int{
+			"total":      1,
+			"successful": 1,
+			"failed":     0,
+		},
+	})
+}
+
+// healthRanks orders the health statuses from worst to best.
+var healthRanks = map[string]int{
+	"red":    0,
+	"yellow": 1,
+	"green":  2,
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	res := map[string]interface{}{
+		"cluster_name": clusterName,
+		"status":       s.health,
+		"timed_out":    false,
+	}
+	wait := r.URL.Query().Get("wait_for_status")
+	if wait != "" && healthRanks[s.health] < healthRanks[wait] {
+		// the status is never reached, so the request always times out
+		res["timed_out"] = true
+		writeJSON(w, http.StatusRequestTimeout, res)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleCatIndices(w http.ResponseWriter, r *http.Request, segs []string) {
+	pattern := "_all"
+	if len(segs) > 0 {
+		pattern = segs[0]
+	}
+	s.mutex.Lock()
+	names := s.resolve(pattern)
+	s.mutex.Unlock()
+	if r.URL.Query().Get("format") == "json" {
+		rows := make([]map[string]string, len(names))
+		for i, name := range names {
+			rows[i] = map[string]string{
+				"index": name,
+			}
+		}
+		writeJSON(w, http.StatusOK, rows)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+	for _, name := range names {
+		fmt.Fprintln(w, name)
+	}
+}
+
+// resolve returns the sorted names of the indices matching the expression,
+// which may be a comma separated list of names, aliases and wildcards.
+// Callers must hold the mutex.
+func (s *Server) resolve(expr string) []string {
+	matched := make(map[string]bool)
+	for _, part := range strings.Split(expr, ",") {
+		for name, idx := range s.indices {
+			if part == "_all" || matchPattern(part, name) || idx.aliases[part] {
+				matched[name] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(matched))
+	for name := range matched {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func matchPattern(pattern string, name string) bool {
+	if !strings.Contains(pattern, "*") {
+		return pattern == name
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(name, parts[0]) {
+		return false
+	}
+	name = name[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(name, part)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(part):]
+	}
+	return strings.HasSuffix(name, parts[len(parts)-1])
+}
@@ -0,0 +1,199 @@
+package estest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const snapshotTimeFormat = "2006-01-02T15:04:05.000Z"
+
+type repository struct {
+	body      json.RawMessage
+	snapshots map[string]*snapshot
+}
+
+type snapshot struct {
+	indices []string
+	state   string
+}
+
+// SetSnapshotState sets the state reported for completed snapshots, ex.
+// "PARTIAL". Unless the state is SUCCESS the first shard of every snapshotted
+// index fails. The default is SUCCESS.
+func SetSnapshotState(state string) OptionFunc {
+	return func(s *Server) {
+		s.snapshotState = state
+	}
+}
+
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request, segs []string, body []byte) {
+	switch len(segs) {
+	case 1:
+		s.handleRepository(w, r, segs[0], body)
+	case 2:
+		s.handleCreateSnapshot(w, r, segs[0], segs[1], body)
+	default:
+		s.handleUnknown(w, r)
+	}
+}
+
+func (s *Server) handleRepository(w http.ResponseWriter, r *http.Request, name string, body []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		req := struct {
+			Type string `json:"type"`
+		}{}
+		if err := json.Unmarshal(body, &req); err != nil || req.Type == "" {
+			writeError(w, http.StatusBadRequest, "action_request_validation_exception",
+				"Validation Failed: 1: type is missing;")
+			return
+		}
+		repo, ok := s.repositories[name]
+		if !ok {
+			repo = &repository{
+				snapshots: make(map[string]*snapshot),
+			}
+			s.repositories[name] = repo
+		}
+		repo.body = append(json.RawMessage(nil), body...)
+		writeAcknowledged(w)
+	case http.MethodGet:
+		repo, ok := s.repositories[name]
+		if !ok {
+			writeError(w, http.StatusNotFound, "repository_missing_exception",
+				fmt.Sprintf("[%s] missing", name))
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			name: repo.body,
+		})
+	default:
+		s.handleUnknown(w, r)
+	}
+}
+
+func (s *Server) handleCreateSnapshot(w http.ResponseWriter, r *http.Request, repoName string, name string, body []byte) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		s.handleUnknown(w, r)
+		return
+	}
+	req := struct {
+		Indices interface{} `json:"indices"`
+	}{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "parse_exception", err.Error())
+			return
+		}
+	}
+	expr := "_all"
+	switch indices := req.Indices.(type) {
+	case string:
+		expr = indices
+	case []interface{}:
+		parts := make([]string, len(indices))
+		for i, index := range indices {
+			parts[i] = fmt.Sprint(index)
+		}
+		expr = strings.Join(parts, ",")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	repo, ok := s.repositories[repoName]
+	if !ok {
+		writeError(w, http.StatusNotFound, "repository_missing_exception",
+			fmt.Sprintf("[%s] missing", repoName))
+		return
+	}
+	if _, ok := repo.snapshots[name]; ok {
+		writeError(w, http.StatusBadRequest, "invalid_snapshot_name_exception",
+			fmt.Sprintf("[%s:%s] Invalid snapshot name [%s], snapshot with the same name already exists", repoName, name, name))
+		return
+	}
+	names := s.resolve(expr)
+	if len(names) == 0 {
+		writeError(w, http.StatusNotFound, "index_not_found_exception",
+			fmt.Sprintf("no such index [%s]", expr))
+		return
+	}
+	state := s.snapshotState
+	repo.snapshots[name] = &snapshot{
+		indices: names,
+		state:   state,
+	}
+	if r.URL.Query().Get("wait_for_completion") != "true" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"accepted": true,
+		})
+		return
+	}
+	total := 0
+	failures := []map[string]interface{}{}
+	for _, index := range names {
+		shards, _ := strconv.Atoi(s.indices[index].settings["index.number_of_shards"])
+		total += shards
+		if state != "SUCCESS" {
+			failures = append(failures, map[string]interface{}{
+				"index":    index,
+				"shard_id": 0,
+				"reason":   "simulated shard failure",
+				"status":   "INTERNAL_SERVER_ERROR",
+			})
+		}
+	}
+	now := time.Now().UTC()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"snapshot": map[string]interface{}{
+			"snapshot":             name,
+			"uuid":                 fmt.Sprintf("%s-%s", repoName, name),
+			"version":              s.version,
+			"indices":              names,
+			"include_global_state": false,
+			"state":                state,
+			"start_time":           now.Format(snapshotTimeFormat),
+			"start_time_in_millis": now.UnixNano() / int64(time.Millisecond),
+			"end_time":             now.Format(snapshotTimeFormat),
+			"end_time_in_millis":   now.UnixNano() / int64(time.Millisecond),
+			"duration_in_millis":   0,
+			"failures":             failures,
+			"shards": map[string]int{
+				"total":      total,
+				"failed":     len(failures),
+				"successful": total - len(failures),
+			},
+		},
+	})
+}
+
+// SnapshotRepository returns the request body of the snapshot repository.
+func (s *Server) SnapshotRepository(name string) (json.RawMessage, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	repo, ok := s.repositories[name]
+	if !ok {
+		return nil, false
+	}
+	return repo.body, true
+}
+
+// SnapshotIndices returns the sorted names of the indices in the snapshot, or
+// nil if the snapshot does not exist.
+func (s *Server) SnapshotIndices(repoName string, name string) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	repo, ok := s.repositories[repoName]
+	if !ok {
+		return nil
+	}
+	snap, ok := repo.snapshots[name]
+	if !ok {
+		return nil
+	}
+	return snap.indices
+}
@@ -0,0 +1,243 @@
+package estest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+type template struct {
+	patterns   []string
+	priority   int
+	composedOf []string
+	settings   map[string]string
+	// the mappings are kept encoded so each index receives its own copy
+	mappings json.RawMessage
+	body     json.RawMessage
+}
+
+func newTemplate(body []byte, settings map[string]interface{}, mappings map[string]interface{}) *template {
+	t := &template{
+		settings: make(map[string]string),
+		body:     append(json.RawMessage(nil), body...),
+	}
+	flattenSettings("", settings, t.settings)
+	if mappings != nil {
+		t.mappings, _ = json.Marshal(mappings)
+	}
+	return t
+}
+
+func (t *template) matches(name string) bool {
+	for _, pattern := range t.patterns {
+		if matchPattern(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *template) applyTo(idx *index) {
+	for key, value := range t.settings {
+		idx.settings[key] = value
+	}
+	if t.mappings != nil {
+		mappings := make(map[string]interface{})
+		json.Unmarshal(t.mappings, &mappings)
+		mergeMaps(idx.mappings, mappings)
+	}
+}
+
+// createIndex creates and stores a new index, applying the settings and
+// mappings of the templates that match its name. A matching composable
+// template takes precedence over legacy templates. Callers must hold the
+// mutex.
+func (s *Server) createIndex(name string) *index {
+	idx := newIndex(name)
+	var composable *template
+	for _, t := range s.indexTemplates {
+		if t.matches(name) && (composable == nil || t.priority > composable.priority) {
+			composable = t
+		}
+	}
+	if composable != nil {
+		for _, component := range composable.composedOf {
+			s.componentTemplates[component].applyTo(idx)
+		}
+		composable.applyTo(idx)
+	} else {
+		var legacy []*template
+		for _, t := range s.templates {
+			if t.matches(name) {
+				legacy = append(legacy, t)
+			}
+		}
+		// higher orders override lower orders
+		sort.Slice(legacy, func(i, j int) bool {
+			return legacy[i].priority < legacy[j].priority
+		})
+		for _, t := range legacy {
+			t.applyTo(idx)
+		}
+	}
+	s.indices[name] = idx
+	return idx
+}
+
+func (s *Server) handleTemplate(w http.ResponseWriter, r *http.Request, templates map[string]*template, segs []string, body []byte, parse func([]byte) (*template, error)) {
+	if len(segs) != 1 {
+		s.handleUnknown(w, r)
+		return
+	}
+	name := segs[0]
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		t, err := parse(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "action_request_validation_exception", err.Error())
+			return
+		}
+		templates[name] = t
+		writeAcknowledged(w)
+	case http.MethodGet, http.MethodHead:
+		t, ok := templates[name]
+		if !ok {
+			writeError(w, http.StatusNotFound, "resource_not_found_exception",
+				fmt.Sprintf("template [%s] missing", name))
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			name: t.body,
+		})
+	case http.MethodDelete:
+		if _, ok := templates[name]; !ok {
+			writeError(w, http.StatusNotFound, "resource_not_found_exception",
+				fmt.Sprintf("template [%s] missing", name))
+			return
+		}
+		delete(templates, name)
+		writeAcknowledged(w)
+	default:
+		s.handleUnknown(w, r)
+	}
+}
+
+// parseTemplate parses a legacy index template. Callers must hold the mutex.
+func (s *Server) parseTemplate(body []byte) (*template, error) {
+	req := struct {
+		IndexPatterns []string               `json:"index_patterns"`
+		Template      string                 `json:"template"`
+		Order         int                    `json:"order"`
+		Settings      map[string]interface{} `json:"settings"`
+		Mappings      map[string]interface{} `json:"mappings"`
+	}{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	if req.Template != "" && s.major < 7 {
+		// index patterns were introduced in 6.x
+		req.IndexPatterns = append(req.IndexPatterns, req.Template)
+	}
+	if len(req.IndexPatterns) == 0 {
+		return nil, fmt.Errorf("Validation Failed: 1: index patterns are missing;")
+	}
+	t := newTemplate(body, req.Settings, req.Mappings)
+	t.patterns = req.IndexPatterns
+	t.priority = req.Order
+	return t, nil
+}
+
+// parseIndexTemplate parses a composable index template. Callers must hold the
+// mutex.
+func (s *Server) parseIndexTemplate(body []byte) (*template, error) {
+	req := struct {
+		IndexPatterns []string `json:"index_patterns"`
+		Priority      int      `json:"priority"`
+		ComposedOf    []string `json:"composed_of"`
+		Template      struct {
+			Settings map[string]interface{} `json:"settings"`
+			Mappings map[string]interface{} `json:"mappings"`
+		} `json:"template"`
+	}{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	if len(req.IndexPatterns) == 0 {
+		return nil, fmt.Errorf("Validation Failed: 1: index patterns are missing;")
+	}
+	for _, component := range req.ComposedOf {
+		if _, ok := s.componentTemplates[component]; !ok {
+			return nil, fmt.Errorf("index template specifies component templates [%s] that do not exist", component)
+		}
+	}
+	t := newTemplate(body, req.Template.Settings, req.Template.Mappings)
+	t.patterns = req.IndexPatterns
+	t.priority = req.Priority
+	t.composedOf = req.ComposedOf
+	return t, nil
+}
+
+// parseComponentTemplate parses a component template.
+func (s *Server) parseComponentTemplate(body []byte) (*template, error) {
+	req := struct {
+		Template *struct {
+			Settings map[string]interface{} `json:"settings"`
+			Mappings map[string]interface{} `json:"mappings"`
+		} `json:"template"`
+	}{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	if req.Template == nil {
+		return nil, fmt.Errorf("Validation Failed: 1: template is missing;")
+	}
+	return newTemplate(body, req.Template.Settings, req.Template.Mappings), nil
+}
+
+// parsePolicy parses an index lifecycle policy, which is kept as a template
+// without patterns.
+func (s *Server) parsePolicy(body []byte) (*template, error) {
+	req := struct {
+		Policy map[string]interface{} `json:"policy"`
+	}{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	if req.Policy == nil {
+		return nil, fmt.Errorf("Validation Failed: 1: policy is missing;")
+	}
+	return newTemplate(body, nil, nil), nil
+}
+
+func (s *Server) templateBody(templates map[string]*template, name string) (json.RawMessage, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	t, ok := templates[name]
+	if !ok {
+		return nil, false
+	}
+	return t.body, true
+}
+
+// Template returns the request body of the legacy index template.
+func (s *Server) Template(name string) (json.RawMessage, bool) {
+	return s.templateBody(s.templates, name)
+}
+
+// IndexTemplate returns the request body of the composable index template.
+func (s *Server) IndexTemplate(name string) (json.RawMessage, bool) {
+	return s.templateBody(s.indexTemplates, name)
+}
+
+// ComponentTemplate returns the request body of the component template.
+func (s *Server) ComponentTemplate(name string) (json.RawMessage, bool) {
+	return s.templateBody(s.componentTemplates, name)
+}
+
+// LifecyclePolicy returns the request body of the index lifecycle policy.
+func (s *Server) LifecyclePolicy(name string) (json.RawMessage, bool) {
+	return s.templateBody(s.policies, name)
+}
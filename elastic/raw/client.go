@@ -0,0 +1,191 @@
+package elastic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/unchartedsoftware/deluge/elastic/rest"
+)
+
+const (
+	defaultURL = "http://127.0.0.1:9200"
+)
+
+// ClientOptionFunc is a function that configures the client. It is used in
+// NewClient.
+type ClientOptionFunc func(*Client) error
+
+// SetURL defines the URL endpoints of the Elasticsearch nodes. Requests are
+// distributed across the nodes in a round-robin fashion.
+func SetURL(urls ...string) ClientOptionFunc {
+	return func(c *Client) error {
+		if len(urls) == 0 {
+			return errors.New("at least one url must be provided")
+		}
+		c.transport.urls = nil
+		for _, u := range urls {
+			c.transport.urls = append(c.transport.urls, strings.TrimRight(u, "/"))
+		}
+		return nil
+	}
+}
+
+// SetBasicAuth can be used to specify the HTTP Basic Auth credentials to
+// use when making HTTP requests to Elasticsearch.
+func SetBasicAuth(username string, password string) ClientOptionFunc {
+	return func(c *Client) error {
+		c.transport.username = username
+		c.transport.password = password
+		return nil
+	}
+}
+
+// SetAPIKey sets the base64-encoded API key used for authorization. If set,
+// it overrides basic auth credentials.
+func SetAPIKey(apiKey string) ClientOptionFunc {
+	return func(c *Client) error {
+		c.transport.header.Set("Authorization", "ApiKey "+apiKey)
+		return nil
+	}
+}
+
+// SetHTTPClient can be used to specify the http.Client to use when making
+// HTTP requests to Elasticsearch.
+func SetHTTPClient(client *http.Client) ClientOptionFunc {
+	return func(c *Client) error {
+		c.transport.client = client
+		return nil
+	}
+}
+
+// SetHeader sets a global HTTP header sent with every request.
+func SetHeader(name string, value string) ClientOptionFunc {
+	return func(c *Client) error {
+		c.transport.header.Set(name, value)
+		return nil
+	}
+}
+
+// SetVersion sets the version of the cluster, ex. "6.8.0", skipping the
+// version detection performed on startup.
+func SetVersion(version string) ClientOptionFunc {
+	return func(c *Client) error {
+		c.version = version
+		return nil
+	}
+}
+
+// SetDistribution sets the distribution of the cluster, ex. "opensearch".
+// It is only required alongside SetVersion, as the distribution is otherwise
+// detected on startup.
+func SetDistribution(distribution string) ClientOptionFunc {
+	return func(c *Client) error {
+		c.distribution = distribution
+		return nil
+	}
+}
+
+// transport performs requests against the nodes of the cluster in a
+// round-robin fashion, using only the standard library.
+type transport struct {
+	client   *http.Client
+	urls     []string
+	next     uint32
+	username string
+	password string
+	header   http.Header
+}
+
+// Perform resolves the relative URL of the request against the next node and
+// executes it.
+func (t *transport) Perform(req *http.Request) (*http.Response, error) {
+	next := atomic.AddUint32(&t.next, 1)
+	endpoint, err := url.Parse(t.urls[int(next)%len(t.urls)] + req.URL.RequestURI())
+	if err != nil {
+		return nil, err
+	}
+	req.URL = endpoint
+	req.Host = endpoint.Host
+	for name, values := range t.header {
+		req.Header[name] = values
+	}
+	if t.username != "" && req.Header.Get("Authorization") == "" {
+		req.SetBasicAuth(t.username, t.password)
+	}
+	return t.client.Do(req)
+}
+
+// Client represents an elasticsearch client built directly on the REST API.
+// The version of the cluster is detected when the client is created, and
+// document types are only sent to clusters that still support them.
+type Client struct {
+	*rest.TemplateClient
+	transport    *transport
+	version      string
+	distribution string
+}
+
+// NewClient returns a new elasticsearch client.
+func NewClient(shards uint16, options ...ClientOptionFunc) (*Client, error) {
+	c := &Client{
+		transport: &transport{
+			client: http.DefaultClient,
+			urls:   []string{defaultURL},
+			header: http.Header{},
+		},
+	}
+	for _, option := range options {
+		if err := option(c); err != nil {
+			return nil, err
+		}
+	}
+	if shards == 0 {
+		return nil, errors.New("shards cannot be 0")
+	}
+	if c.version == "" {
+		// detect the version of the cluster
+		probe, err := rest.NewClient(c.transport, shards)
+		if err != nil {
+			return nil, err
+		}
+		info := &struct {
+			Version struct {
+				Number       string `json:"number"`
+				Distribution string `json:"distribution"`
+			} `json:"version"`
+		}{}
+		_, err = probe.Perform(context.Background(), http.MethodGet, "/", nil, info)
+		if err != nil {
+			return nil, fmt.Errorf("Error occurred while detecting cluster version: %v", err)
+		}
+		c.version = info.Version.Number
+		c.distribution = info.Version.Distribution
+	}
+	major, err := strconv.Atoi(strings.SplitN(c.version, ".", 2)[0])
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse cluster version `%s`", c.version)
+	}
+	if c.distribution == "opensearch" {
+		// opensearch was forked from 7.10.2, its versions restarted at 1.x
+		major = 7
+	}
+	client, err := rest.NewClient(c.transport, shards, rest.SetMajorVersion(major))
+	if err != nil {
+		return nil, err
+	}
+	c.TemplateClient = &rest.TemplateClient{
+		Client: client,
+	}
+	return c, nil
+}
+
+// Version returns the version of the cluster.
+func (c *Client) Version() string {
+	return c.version
+}
@@ -0,0 +1,87 @@
+package elastic
+
+import (
+	"testing"
+
+	"github.com/unchartedsoftware/deluge/elastic/estest"
+	"github.com/unchartedsoftware/deluge/elastic/estest/clienttest"
+)
+
+func newClient(url string) (clienttest.Client, error) {
+	return NewClient(2, SetURL(url))
+}
+
+func TestClient2(t *testing.T) {
+	clienttest.Run(t, &clienttest.Config{
+		NewClient: newClient,
+		Options: []estest.OptionFunc{
+			estest.SetVersion("2.4.6"),
+		},
+		Type:      "datum",
+		Shards:    2,
+		Templates: clienttest.TemplatesLegacy,
+	})
+}
+
+func TestClient5(t *testing.T) {
+	clienttest.Run(t, &clienttest.Config{
+		NewClient: newClient,
+		Options: []estest.OptionFunc{
+			estest.SetVersion("5.6.16"),
+		},
+		Type:      "datum",
+		Shards:    2,
+		Pipelines: true,
+		Templates: clienttest.TemplatesLegacy,
+	})
+}
+
+func TestClient6(t *testing.T) {
+	clienttest.Run(t, &clienttest.Config{
+		NewClient: newClient,
+		Options: []estest.OptionFunc{
+			estest.SetVersion("6.8.23"),
+		},
+		Type:      "_doc",
+		Shards:    2,
+		Pipelines: true,
+		SeqNo:     true,
+	})
+}
+
+func TestClient7(t *testing.T) {
+	clienttest.Run(t, &clienttest.Config{
+		NewClient: newClient,
+		Shards:    2,
+		Pipelines: true,
+		SeqNo:     true,
+		Templates: clienttest.TemplatesComposable,
+	})
+}
+
+func TestClient8(t *testing.T) {
+	clienttest.Run(t, &clienttest.Config{
+		NewClient: newClient,
+		Options: []estest.OptionFunc{
+			estest.SetVersion("8.4.0"),
+		},
+		Shards:    2,
+		Pipelines: true,
+		SeqNo:     true,
+		Templates: clienttest.TemplatesComposable,
+	})
+}
+
+func TestClientOpenSearch(t *testing.T) {
+	clienttest.Run(t, &clienttest.Config{
+		NewClient: newClient,
+		Options: []estest.OptionFunc{
+			estest.SetVersion("2.11.0"),
+			estest.SetDistribution("opensearch"),
+		},
+		Shards:    2,
+		Pipelines: true,
+		SeqNo:     true,
+		Templates: clienttest.TemplatesComposable,
+	})
+}
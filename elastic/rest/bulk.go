@@ -0,0 +1,308 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/unchartedsoftware/deluge"
+	"github.com/unchartedsoftware/deluge/backoff"
+)
+
+// BulkRequest represents an elasticsearch bulk request. Documents are
+// serialized into a single NDJSON buffer as they are added, which is then
+// streamed directly into the request body.
+type BulkRequest struct {
+	client  *Client
+	index   string
+	buffer  bytes.Buffer
+	items   []*bulkItem
+	failed  []*deluge.BulkItemError
+	backoff *backoff.Backoff
+	start   time.Time
+}
+
+type bulkItem struct {
+	// the offsets of the action and source lines within the buffer
+	offset int
+	length int
+	err    error
+	action string
+}
+
+type bulkResponse struct {
+	Took   int64                          `json:"took"`
+	Errors bool                           `json:"errors"`
+	Items  []map[string]*bulkResponseItem `json:"items"`
+}
+
+type bulkResponseItem struct {
+	ID     string `json:"_id"`
+	Status int    `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// Add adds a bulkable index request to the bulk payload. Pre-serialized
+// sources, ex. a string or a json.RawMessage, are written as-is.
+// typ is ignored if the cluster does not support types.
+func (r *BulkRequest) Add(typ string, id string, source interface{}) {
+	r.AddItem(&deluge.BulkItem{
+		Operation: deluge.OperationIndex,
+		Type:      typ,
+		ID:        id,
+		Source:    source,
+	})
+}
+
+// AddItem adds a bulkable request for the operation of the item to the bulk
+// payload.
+func (r *BulkRequest) AddItem(item *deluge.BulkItem) {
+	op, body := r.bulkBody(item)
+	action, _ := json.Marshal(map[string]interface{}{
+		op: r.bulkMeta(op, item),
+	})
+	req := &bulkItem{
+		action: string(action),
+	}
+	var src []byte
+	if body != nil {
+		src, req.err = encodeSource(body)
+	}
+	r.items = append(r.items, req)
+	if req.err != nil {
+		return
+	}
+	req.offset = r.buffer.Len()
+	r.buffer.Write(action)
+	r.buffer.WriteByte('\n')
+	if body != nil {
+		r.buffer.Write(src)
+		r.buffer.WriteByte('\n')
+	}
+	req.length = r.buffer.Len() - req.offset
+}
+
+// bulkMeta returns the metadata of the bulk action for the item. Fields that
+// are not supported by the cluster version are omitted.
+func (r *BulkRequest) bulkMeta(op string, item *deluge.BulkItem) map[string]interface{} {
+	meta := map[string]interface{}{
+		"_id": item.ID,
+	}
+	if item.Index != "" {
+		meta["_index"] = item.Index
+	}
+	if !r.client.typeless {
+		meta["_type"] = item.Type
+	}
+	m := item.Metadata
+	if m == nil {
+		return meta
+	}
+	// underscore prefixed metadata was deprecated in 6.x
+	prefix := ""
+	if r.client.major < 6 {
+		prefix = "_"
+	}
+	if m.Routing != "" {
+		meta[prefix+"routing"] = m.Routing
+	}
+	if m.Version != nil {
+		meta[prefix+"version"] = *m.Version
+	}
+	if m.VersionType != "" {
+		meta[prefix+"version_type"] = m.VersionType
+	}
+	if r.client.major >= 6 {
+		if m.IfSeqNo != nil {
+			meta["if_seq_no"] = *m.IfSeqNo
+		}
+		if m.IfPrimaryTerm != nil {
+			meta["if_primary_term"] = *m.IfPrimaryTerm
+		}
+	}
+	// pipelines were added in 5.x and only apply to indexed documents
+	if m.Pipeline != "" && r.client.major >= 5 && (op == "index" || op == "create") {
+		meta["pipeline"] = m.Pipeline
+	}
+	return meta
+}
+
+// bulkBody returns the bulk action and body for the operation of the item. A
+// nil body is returned for operations without a body.
+func (r *BulkRequest) bulkBody(item *deluge.BulkItem) (string, interface{}) {
+	switch item.Operation {
+	case deluge.OperationCreate:
+		return "create", item.Source
+	case deluge.OperationUpdate:
+		return "update", map[string]interface{}{
+			"doc": rawSource(item.Source),
+		}
+	case deluge.OperationUpsert:
+		return "update", map[string]interface{}{
+			"doc":           rawSource(item.Source),
+			"doc_as_upsert": true,
+		}
+	case deluge.OperationScript:
+		script := map[string]interface{}{}
+		if s, ok := item.Source.(*deluge.Script); ok && s != nil {
+			// inline scripts were renamed in 6.x
+			key := "source"
+			if r.client.major < 6 {
+				key = "inline"
+			}
+			script[key] = s.Source
+			if s.Lang != "" {
+				script["lang"] = s.Lang
+			}
+			if len(s.Params) > 0 {
+				script["params"] = s.Params
+			}
+		}
+		return "update", map[string]interface{}{
+			"script": script,
+		}
+	case deluge.OperationDelete:
+		return "delete", nil
+	}
+	return "index", item.Source
+}
+
+// rawSource returns pre-serialized sources as a json.RawMessage, so that they
+// are not encoded as JSON strings.
+func rawSource(source interface{}) interface{} {
+	switch s := source.(type) {
+	case string:
+		return json.RawMessage(s)
+	case *string:
+		if s != nil {
+			return json.RawMessage(*s)
+		}
+	case []byte:
+		return json.RawMessage(s)
+	}
+	return source
+}
+
+// encodeSource serializes the source of a bulk item. Pre-serialized sources
+// are only compacted, as any newlines would break the NDJSON.
+func encodeSource(source interface{}) ([]byte, error) {
+	raw, ok := rawSource(source).(json.RawMessage)
+	if !ok {
+		return json.Marshal(source)
+	}
+	var compact bytes.Buffer
+	err := json.Compact(&compact, raw)
+	return compact.Bytes(), err
+}
+
+// EstimatedSizeInBytes returns the estimated size in bytes.
+func (r *BulkRequest) EstimatedSizeInBytes() int64 {
+	return int64(r.buffer.Len())
+}
+
+// Size returns the number of documents.
+func (r *BulkRequest) Size() int {
+	return len(r.items)
+}
+
+// Took returns the time it took to generate the request.
+func (r *BulkRequest) Took() uint64 {
+	return uint64((time.Since(r.start)).Nanoseconds()) / uint64(time.Millisecond)
+}
+
+// body returns a reader streaming the serialized items from the buffer.
+func (r *BulkRequest) body(items []int) io.Reader {
+	buffer := r.buffer.Bytes()
+	readers := make([]io.Reader, len(items))
+	for i, index := range items {
+		item := r.items[index]
+		readers[i] = bytes.NewReader(buffer[item.offset : item.offset+item.length])
+	}
+	return io.MultiReader(readers...)
+}
+
+// Send sends the bulk request and handles the response. Items rejected due
+// to back pressure are resent with an exponential backoff. Documents that were
+// permanently rejected do not fail the request, and are instead available
+// through Failed.
+func (r *BulkRequest) Send(ctx context.Context) (uint64, error) {
+	r.failed = nil
+	took := uint64(0)
+	// the positions of the items being sent within the original request
+	var items []int
+	for index, item := range r.items {
+		if item.err != nil {
+			// the document could not be serialized
+			r.failed = append(r.failed, &deluge.BulkItemError{
+				Item:   index,
+				Type:   "serialization_exception",
+				Reason: item.err.Error(),
+				Action: item.action,
+			})
+			continue
+		}
+		items = append(items, index)
+	}
+	buffer := r.buffer.Bytes()
+	for attempt := 1; len(items) > 0; attempt++ {
+		req, err := r.client.newRequest(ctx, http.MethodPost, "/"+url.PathEscape(r.index)+"/_bulk", r.body(items))
+		if err != nil {
+			return took, err
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		res := &bulkResponse{}
+		_, err = r.client.do(req, res)
+		if err != nil {
+			return took, err
+		}
+		took += uint64(res.Took)
+		var retries []int
+		if res.Errors {
+			// collect every failed item
+			for index, results := range res.Items {
+				for _, action := range results {
+					if action.Error == nil {
+						continue
+					}
+					item := items[index]
+					if attempt < r.backoff.MaxAttempts() &&
+						backoff.IsRetryable(action.Status, action.Error.Type) {
+						retries = append(retries, item)
+						continue
+					}
+					offset := r.items[item].offset
+					r.failed = append(r.failed, &deluge.BulkItemError{
+						Item:   item,
+						ID:     action.ID,
+						Status: action.Status,
+						Type:   action.Error.Type,
+						Reason: action.Error.Reason,
+						Action: string(bytes.TrimSuffix(buffer[offset:offset+r.items[item].length], []byte{'\n'})),
+					})
+				}
+			}
+		}
+		if len(retries) == 0 {
+			break
+		}
+		// wait and then resend only the retryable items
+		err = r.backoff.Wait(ctx, attempt)
+		if err != nil {
+			return took, err
+		}
+		items = retries
+	}
+	return took, nil
+}
+
+// Failed returns the documents that were rejected by the last Send.
+func (r *BulkRequest) Failed() []*deluge.BulkItemError {
+	return r.failed
+}
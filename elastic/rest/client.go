@@ -0,0 +1,507 @@
+// Package rest provides the shared implementation of the elasticsearch
+// clients built directly on the REST API, on top of their transports.
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/unchartedsoftware/deluge"
+	"github.com/unchartedsoftware/deluge/backoff"
+	es "github.com/unchartedsoftware/deluge/input/elastic"
+)
+
+const (
+	scrollTimeout = "1m"
+	// the major version of the cluster unless specified otherwise
+	defaultMajorVersion = 8
+)
+
+// Transport represents the transport of an elasticsearch compatible client,
+// ex. an *elasticsearch.Client or an *opensearch.Client. The transport
+// resolves relative request URLs against the nodes of the cluster.
+type Transport interface {
+	Perform(*http.Request) (*http.Response, error)
+}
+
+// ClientOptionFunc is a function that configures the client. It is used in
+// NewClient.
+type ClientOptionFunc func(*Client) error
+
+// SetMajorVersion sets the major version of the cluster, ex. 6. Document
+// types are only sent to clusters that still support them, and metadata is
+// named according to the version. The default is 8.
+func SetMajorVersion(major int) ClientOptionFunc {
+	return func(c *Client) error {
+		if major < 2 {
+			return fmt.Errorf("Unsupported major version %d", major)
+		}
+		c.major = major
+		return nil
+	}
+}
+
+// Client represents a client for elasticsearch compatible clusters, ex.
+// elasticsearch 8.x.x or opensearch, built directly on the REST API.
+type Client struct {
+	transport Transport
+	backoff   *backoff.Backoff
+	shards    uint16
+	major     int
+	typeless  bool
+}
+
+// NewClient returns a new client performing its requests through the provided
+// transport.
+func NewClient(transport Transport, shards uint16, options ...ClientOptionFunc) (*Client, error) {
+	if shards == 0 {
+		return nil, errors.New("shards cannot be 0")
+	}
+	c := &Client{
+		transport: transport,
+		backoff:   backoff.Default(),
+		shards:    shards,
+		major:     defaultMajorVersion,
+	}
+	for _, option := range options {
+		if err := option(c); err != nil {
+			return nil, err
+		}
+	}
+	// types were removed in 7.x
+	c.typeless = c.major >= 7
+	return c, nil
+}
+
+type acknowledgedResponse struct {
+	Acknowledged bool `json:"acknowledged"`
+}
+
+type healthResponse struct {
+	Status   string `json:"status"`
+	TimedOut bool   `json:"timed_out"`
+}
+
+type errorResponse struct {
+	Error struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+	Status int `json:"status"`
+}
+
+func decodeError(res *http.Response) error {
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("elastic: Error %s", res.Status)
+	}
+	e := &errorResponse{}
+	err = json.Unmarshal(body, e)
+	if err != nil || e.Error.Type == "" {
+		return fmt.Errorf("elastic: Error %s", res.Status)
+	}
+	return fmt.Errorf("elastic: Error %d (%s): %s [type=%s]",
+		res.StatusCode,
+		http.StatusText(res.StatusCode),
+		e.Error.Reason,
+		e.Error.Type)
+}
+
+func (c *Client) newRequest(ctx context.Context, method string, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// do executes the request through the transport and decodes the JSON response
+// into the provided value. Any status codes flagged as ignored are returned
+// without error and without decoding.
+func (c *Client) do(req *http.Request, result interface{}, ignore ...int) (int, error) {
+	res, err := c.transport.Perform(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	for _, status := range ignore {
+		if res.StatusCode == status {
+			return res.StatusCode, nil
+		}
+	}
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return res.StatusCode, decodeError(res)
+	}
+	if result != nil {
+		err = json.NewDecoder(res.Body).Decode(result)
+		if err != nil {
+			return res.StatusCode, err
+		}
+	}
+	return res.StatusCode, nil
+}
+
+// Perform executes a JSON request against the provided path and decodes the
+// JSON response into the provided value. A string or io.Reader body is sent
+// as-is, any other body is JSON encoded. Any status codes flagged as ignored
+// are returned without error and without decoding.
+func (c *Client) Perform(ctx context.Context, method string, path string, body interface{}, result interface{}, ignore ...int) (int, error) {
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	case io.Reader:
+		reader = b
+	default:
+		bs, err := json.Marshal(b)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(bs)
+	}
+	req, err := c.newRequest(ctx, method, path, reader)
+	if err != nil {
+		return 0, err
+	}
+	return c.do(req, result, ignore...)
+}
+
+func (c *Client) putSettings(index string, body string) (*acknowledgedResponse, error) {
+	res := &acknowledgedResponse{}
+	_, err := c.Perform(context.Background(), http.MethodPut, "/"+url.PathEscape(index)+"/_settings", body, res)
+	return res, err
+}
+
+// NewBulkRequest returns a new bulk request struct.
+func (c *Client) NewBulkRequest(index string) deluge.BulkRequest {
+	return &BulkRequest{
+		client:  c,
+		index:   index,
+		backoff: c.backoff,
+		start:   time.Now(),
+	}
+}
+
+// SetBulkBackoff sets the backoff used when retrying bulk items that were
+// rejected due to back pressure.
+func (c *Client) SetBulkBackoff(b *backoff.Backoff) {
+	c.backoff = b
+}
+
+// IndexExists returns whether or not the specified index exists.
+func (c *Client) IndexExists(index string) (bool, error) {
+	status, err := c.Perform(context.Background(), http.MethodHead, "/"+url.PathEscape(index), nil, nil, http.StatusNotFound)
+	if err != nil {
+		return false, err
+	}
+	return status == http.StatusOK, nil
+}
+
+// DeleteIndex deletes the specified index.
+func (c *Client) DeleteIndex(index string) error {
+	res := &acknowledgedResponse{}
+	_, err := c.Perform(context.Background(), http.MethodDelete, "/"+url.PathEscape(index), nil, res)
+	if err != nil {
+		return fmt.Errorf("Error occurred while deleting index: %v", err)
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("Delete index request not acknowledged for index: `%s`", index)
+	}
+	return nil
+}
+
+// CreateIndex creates the specified index with the provided mapping. The
+// provided settings take precedence over the default settings.
+func (c *Client) CreateIndex(index string, mapping string, settings string) error {
+	// prepare the create index body
+	merged, err := deluge.MergeSettings(settings, map[string]interface{}{
+		"index.number_of_replicas": 0,
+		"index.number_of_shards":   c.shards,
+	})
+	if err != nil {
+		return fmt.Errorf("Error occurred while creating index: %v", err)
+	}
+	body := fmt.Sprintf("{\"mappings\":%s,\"settings\":%s}", mapping, merged)
+	res := &acknowledgedResponse{}
+	_, err = c.Perform(context.Background(), http.MethodPut, "/"+url.PathEscape(index), body, res)
+	if err != nil {
+		return fmt.Errorf("Error occurred while creating index: %v", err)
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("Create index request not acknowledged for `%s`", index)
+	}
+	return nil
+}
+
+// PutMapping uploads the provided mapping.
+// typ is ignored if the cluster does not support types.
+func (c *Client) PutMapping(index string, typ string, mapping string) error {
+	path := "/" + url.PathEscape(index) + "/_mapping"
+	if !c.typeless {
+		path += "/" + url.PathEscape(typ)
+	}
+	res := &acknowledgedResponse{}
+	_, err := c.Perform(context.Background(), http.MethodPut, path, mapping, res)
+	if err != nil {
+		return fmt.Errorf("Error occurred while updating mapping for index: %v", err)
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("Put mapping request not acknowledged for `%s`", index)
+	}
+	return nil
+}
+
+// EnableReplicas enables the provided number of replicas.
+func (c *Client) EnableReplicas(index string, numReplicas int) error {
+	body := fmt.Sprintf("{\"index\":{\"number_of_replicas\":%d}}", numReplicas)
+	res, err := c.putSettings(index, body)
+	if err != nil {
+		return fmt.Errorf("Error occurred while enabling replicas: %v", err)
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("Enable replication index request not acknowledged for index `%s`", index)
+	}
+	return nil
+}
+
+// PutSettings updates the dynamic settings of the specified index with the
+// provided JSON encoded settings.
+func (c *Client) PutSettings(index string, settings string) error {
+	res, err := c.putSettings(index, settings)
+	if err != nil {
+		return fmt.Errorf("Error occurred while updating settings for index: %v", err)
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("Put settings request not acknowledged for index `%s`", index)
+	}
+	return nil
+}
+
+// Refresh refreshes the specified index, making all ingested documents
+// visible to search.
+func (c *Client) Refresh(index string) error {
+	_, err := c.Perform(context.Background(), http.MethodPost, "/"+url.PathEscape(index)+"/_refresh", nil, nil)
+	if err != nil {
+		return fmt.Errorf("Error occurred while refreshing index: %v", err)
+	}
+	return nil
+}
+
+// Count returns the number of documents in the specified index, as visible
+// to search.
+func (c *Client) Count(index string) (int64, error) {
+	res := &struct {
+		Count int64 `json:"count"`
+	}{}
+	_, err := c.Perform(context.Background(), http.MethodGet, "/"+url.PathEscape(index)+"/_count", nil, res)
+	if err != nil {
+		return 0, fmt.Errorf("Error occurred while counting documents of index: %v", err)
+	}
+	return res.Count, nil
+}
+
+// ForceMerge merges the segments of the specified index down to the provided
+// maximum number of segments.
+func (c *Client) ForceMerge(index string, maxNumSegments int) error {
+	path := fmt.Sprintf("/%s/_forcemerge?max_num_segments=%d", url.PathEscape(index), maxNumSegments)
+	_, err := c.Perform(context.Background(), http.MethodPost, path, nil, nil)
+	if err != nil {
+		return fmt.Errorf("Error occurred while force merging index: %v", err)
+	}
+	return nil
+}
+
+// WaitForGreen waits until the health of the specified index is green, or
+// returns an error once the timeout expires.
+func (c *Client) WaitForGreen(index string, timeout time.Duration) error {
+	path := fmt.Sprintf("/_cluster/health/%s?wait_for_status=green&timeout=%dms", url.PathEscape(index), timeout.Milliseconds())
+	res := &healthResponse{}
+	status, err := c.Perform(context.Background(), http.MethodGet, path, nil, res, http.StatusRequestTimeout)
+	if err != nil {
+		return fmt.Errorf("Error occurred while waiting for green health of index: %v", err)
+	}
+	// the request times out with a 408 status code
+	if status == http.StatusRequestTimeout || res.TimedOut || res.Status != "green" {
+		return fmt.Errorf("Index `%s` did not reach green health within %v", index, timeout)
+	}
+	return nil
+}
+
+type indexStats struct {
+	Primaries *struct {
+		Docs *struct {
+			Count int64 `json:"count"`
+		} `json:"docs"`
+		Store *struct {
+			SizeInBytes int64 `json:"size_in_bytes"`
+		} `json:"store"`
+	} `json:"primaries"`
+}
+
+// GetIndexSummary returns an index summary struct.
+func (c *Client) GetIndexSummary(index string) (es.IndexSummary, error) {
+	// get stats about the index
+	stats := &struct {
+		Indices map[string]*indexStats `json:"indices"`
+	}{}
+	_, err := c.Perform(context.Background(), http.MethodGet, "/"+url.PathEscape(index)+"/_stats", nil, stats)
+	if err != nil {
+		return nil, fmt.Errorf("Error occurred while querying index stats for `%s`: %v",
+			index,
+			err)
+	}
+	// don't access by index name, it won't work if this is an alias to an
+	// index. Since we are doing a query for a specific index already, there
+	// should be only one index in the response.
+	if len(stats.Indices) < 1 {
+		return nil, fmt.Errorf("Index `%s` does not exist", index)
+	}
+	// grab the first index in the map (there should only be one)
+	var indexStats *indexStats
+	for _, value := range stats.Indices {
+		indexStats = value
+		break
+	}
+	// get number of documents
+	numDocs := int64(0)
+	// ensure no nil pointers
+	if indexStats.Primaries != nil &&
+		indexStats.Primaries.Docs != nil {
+		numDocs = indexStats.Primaries.Docs.Count
+	}
+	// get the btye size
+	byteSize := int64(0)
+	// ensure no nil pointers
+	if indexStats.Primaries != nil &&
+		indexStats.Primaries.Store != nil {
+		byteSize = indexStats.Primaries.Store.SizeInBytes
+	}
+	return &IndexSummary{
+		numDocs:  uint64(numDocs),
+		byteSize: uint64(byteSize),
+	}, nil
+}
+
+// GetIndexReader returns an index reader struct.
+func (c *Client) GetIndexReader(index string, scanSize int) (es.IndexReader, error) {
+	return &IndexReader{
+		client: c,
+		index:  index,
+		size:   scanSize,
+	}, nil
+}
+
+// SetReadOnly sets the read-only status of an index
+func (c *Client) SetReadOnly(index string, readOnly bool) error {
+	body := fmt.Sprintf("{\"index\":{\"blocks\":{\"read_only\": %v}}}", readOnly)
+	res, err := c.putSettings(index, body)
+	if err != nil {
+		return fmt.Errorf("Error occurred while trying to set read_only attribute of the index: %v", err)
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("Setting read only attribute request not acknowledged for index `%s`", index)
+	}
+	return nil
+}
+
+// SetBlockWrite sets the block-write status of an index
+func (c *Client) SetBlockWrite(index string, blockWrite bool) error {
+	body := fmt.Sprintf("{\"index\":{\"blocks\":{\"write\": %v}}}", blockWrite)
+	res, err := c.putSettings(index, body)
+	if err != nil {
+		return fmt.Errorf("Error occurred while trying to set block_write attribute of the index: %v", err)
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("Setting block write attribute request not acknowledged for index `%s`", index)
+	}
+	return nil
+}
+
+// ListIndices returns the names of all indices beginning with the provided
+// prefix.
+func (c *Client) ListIndices(prefix string) ([]string, error) {
+	// use the plain text output, json is not supported by 2.x
+	req, err := c.newRequest(context.Background(), http.MethodGet, "/_cat/indices/"+url.PathEscape(prefix)+"*?h=index", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.transport.Perform(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error occurred while listing indices: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("Error occurred while listing indices: %v", decodeError(res))
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error occurred while listing indices: %v", err)
+	}
+	var indices []string
+	for _, line := range strings.Split(string(body), "\n") {
+		index := strings.TrimSpace(line)
+		if index != "" {
+			indices = append(indices, index)
+		}
+	}
+	return indices, nil
+}
+
+// GetAliasIndices returns the names of all indices the alias points to.
+func (c *Client) GetAliasIndices(alias string) ([]string, error) {
+	res := make(map[string]interface{})
+	status, err := c.Perform(context.Background(), http.MethodGet, "/_alias/"+url.PathEscape(alias), nil, &res, http.StatusNotFound)
+	if err != nil {
+		return nil, fmt.Errorf("Error occurred while getting alias `%s`: %v", alias, err)
+	}
+	if status == http.StatusNotFound {
+		// alias does not exist yet
+		return nil, nil
+	}
+	var indices []string
+	for index := range res {
+		indices = append(indices, index)
+	}
+	return indices, nil
+}
+
+// UpdateAlias atomically points the alias to the provided index and removes
+// it from the provided previous indices.
+func (c *Client) UpdateAlias(alias string, index string, previous []string) error {
+	actions := []map[string]interface{}{
+		{"add": map[string]string{"index": index, "alias": alias}},
+	}
+	for _, prev := range previous {
+		actions = append(actions, map[string]interface{}{
+			"remove": map[string]string{"index": prev, "alias": alias},
+		})
+	}
+	res := &acknowledgedResponse{}
+	_, err := c.Perform(context.Background(), http.MethodPost, "/_aliases", map[string]interface{}{
+		"actions": actions,
+	}, res)
+	if err != nil {
+		return fmt.Errorf("Error occurred while updating alias `%s`: %v", alias, err)
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("Update alias request not acknowledged for alias `%s`", alias)
+	}
+	return nil
+}
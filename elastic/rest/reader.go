@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// IndexReader represents an interface to read from an elasticsearch index.
+type IndexReader struct {
+	client   *Client
+	index    string
+	size     int
+	scrollID string
+	done     bool
+}
+
+type scrollResponse struct {
+	ScrollID string `json:"_scroll_id"`
+	Hits     struct {
+		Hits []json.RawMessage `json:"hits"`
+	} `json:"hits"`
+}
+
+// Next returns the io.Reader to scan the index for more docs.
+func (i *IndexReader) Next() (io.Reader, error) {
+	if i.done {
+		return nil, io.EOF
+	}
+	res := &scrollResponse{}
+	var err error
+	if i.scrollID == "" {
+		// open the scroll
+		path := "/" + url.PathEscape(i.index) + "/_search?scroll=" + scrollTimeout + "&size=" + strconv.Itoa(i.size)
+		_, err = i.client.Perform(context.Background(), http.MethodPost, path, map[string]interface{}{
+			"sort": []string{"_doc"},
+		}, res)
+	} else {
+		_, err = i.client.Perform(context.Background(), http.MethodPost, "/_search/scroll", map[string]string{
+			"scroll":    scrollTimeout,
+			"scroll_id": i.scrollID,
+		}, res)
+	}
+	if err != nil {
+		return nil, err
+	}
+	i.scrollID = res.ScrollID
+	if len(res.Hits.Hits) == 0 {
+		i.done = true
+		i.clear()
+		return nil, io.EOF
+	}
+	// create buffer
+	var buffer []byte
+	// append the docs as bytes
+	for _, doc := range res.Hits.Hits {
+		// append a newline
+		sub := append(doc, byte('\n'))
+		// add to buffer
+		buffer = append(buffer, sub...)
+	}
+	return bytes.NewReader(buffer), nil
+}
+
+func (i *IndexReader) clear() {
+	if i.scrollID == "" {
+		return
+	}
+	// best effort, the scroll will expire regardless
+	i.client.Perform(context.Background(), http.MethodDelete, "/_search/scroll", map[string][]string{
+		"scroll_id": {i.scrollID},
+	}, nil)
+	i.scrollID = ""
+}
@@ -0,0 +1,103 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/unchartedsoftware/deluge"
+)
+
+// TemplateClient represents a client for clusters supporting index templates,
+// component templates and index lifecycle policies.
+type TemplateClient struct {
+	*Client
+}
+
+// templateBody returns the settings and mappings of the template.
+func templateBody(template *deluge.IndexTemplate) map[string]interface{} {
+	body := make(map[string]interface{})
+	if template.Settings != "" {
+		body["settings"] = json.RawMessage(template.Settings)
+	}
+	if template.Mapping != "" {
+		body["mappings"] = json.RawMessage(template.Mapping)
+	}
+	return body
+}
+
+// PutTemplate installs the provided legacy index template. Templates prior to
+// 6.x only support a single index pattern.
+func (c *TemplateClient) PutTemplate(template *deluge.IndexTemplate) error {
+	body := templateBody(template)
+	body["order"] = template.Priority
+	if c.major < 6 {
+		if len(template.Patterns) != 1 {
+			return fmt.Errorf("Index template `%s` must have exactly one index pattern", template.Name)
+		}
+		body["template"] = template.Patterns[0]
+	} else {
+		body["index_patterns"] = template.Patterns
+	}
+	res := &acknowledgedResponse{}
+	_, err := c.Perform(context.Background(), http.MethodPut, "/_template/"+url.PathEscape(template.Name), body, res)
+	if err != nil {
+		return fmt.Errorf("Error occurred while putting index template: %v", err)
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("Put index template request not acknowledged for `%s`", template.Name)
+	}
+	return nil
+}
+
+// PutIndexTemplate installs the provided composable index template. Composable
+// templates require elasticsearch 7.8 or higher.
+func (c *TemplateClient) PutIndexTemplate(template *deluge.IndexTemplate) error {
+	body := map[string]interface{}{
+		"index_patterns": template.Patterns,
+		"priority":       template.Priority,
+		"template":       templateBody(template),
+	}
+	if len(template.ComposedOf) > 0 {
+		body["composed_of"] = template.ComposedOf
+	}
+	res := &acknowledgedResponse{}
+	_, err := c.Perform(context.Background(), http.MethodPut, "/_index_template/"+url.PathEscape(template.Name), body, res)
+	if err != nil {
+		return fmt.Errorf("Error occurred while putting index template: %v", err)
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("Put index template request not acknowledged for `%s`", template.Name)
+	}
+	return nil
+}
+
+// PutComponentTemplate installs the provided component template. Component
+// templates require elasticsearch 7.8 or higher.
+func (c *TemplateClient) PutComponentTemplate(name string, template string) error {
+	res := &acknowledgedResponse{}
+	_, err := c.Perform(context.Background(), http.MethodPut, "/_component_template/"+url.PathEscape(name), template, res)
+	if err != nil {
+		return fmt.Errorf("Error occurred while putting component template: %v", err)
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("Put component template request not acknowledged for `%s`", name)
+	}
+	return nil
+}
+
+// PutLifecyclePolicy installs the provided index lifecycle policy. Lifecycle
+// policies require elasticsearch 6.6 or higher.
+func (c *TemplateClient) PutLifecyclePolicy(name string, policy string) error {
+	res := &acknowledgedResponse{}
+	_, err := c.Perform(context.Background(), http.MethodPut, "/_ilm/policy/"+url.PathEscape(name), policy, res)
+	if err != nil {
+		return fmt.Errorf("Error occurred while putting lifecycle policy: %v", err)
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("Put lifecycle policy request not acknowledged for `%s`", name)
+	}
+	return nil
+}
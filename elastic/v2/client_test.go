@@ -0,0 +1,20 @@
+package elastic
+
+import (
+	"testing"
+
+	"github.com/unchartedsoftware/deluge/elastic/estest"
+	"github.com/unchartedsoftware/deluge/elastic/estest/clienttest"
+)
+
+func TestClient(t *testing.T) {
+	clienttest.Run(t, &clienttest.Config{
+		NewClient: func(url string) (clienttest.Client, error) {
+			return NewClient(SetURL(url))
+		},
+		Options: []estest.OptionFunc{
+			estest.SetVersion("2.4.6"),
+		},
+		Type: "datum",
+	})
+}
@@ -0,0 +1,112 @@
+package elastic
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/unchartedsoftware/deluge"
+)
+
+type acknowledgedResponse struct {
+	Acknowledged bool `json:"acknowledged"`
+}
+
+type snapshotResponse struct {
+	Snapshot *struct {
+		Snapshot         string   `json:"snapshot"`
+		Indices          []string `json:"indices"`
+		State            string   `json:"state"`
+		DurationInMillis int64    `json:"duration_in_millis"`
+		Failures         []struct {
+			Index   string `json:"index"`
+			ShardID int    `json:"shard_id"`
+			Reason  string `json:"reason"`
+		} `json:"failures"`
+		Shards struct {
+			Total  int `json:"total"`
+			Failed int `json:"failed"`
+		} `json:"shards"`
+	} `json:"snapshot"`
+}
+
+func (r *snapshotResponse) status(repository string) *deluge.SnapshotStatus {
+	s := r.Snapshot
+	status := &deluge.SnapshotStatus{
+		Repository:   repository,
+		Snapshot:     s.Snapshot,
+		State:        s.State,
+		Indices:      s.Indices,
+		TotalShards:  s.Shards.Total,
+		FailedShards: s.Shards.Failed,
+		Duration:     time.Duration(s.DurationInMillis) * time.Millisecond,
+	}
+	for _, failure := range s.Failures {
+		status.Failures = append(status.Failures,
+			fmt.Sprintf("[%s][%d] %s", failure.Index, failure.ShardID, failure.Reason))
+	}
+	return status
+}
+
+// SnapshotRepositoryExists returns whether or not the specified snapshot
+// repository is registered.
+func (c *Client) SnapshotRepositoryExists(name string) (bool, error) {
+	res, err := c.client.PerformRequest(http.MethodGet, "/_snapshot/"+url.PathEscape(name), nil, nil, http.StatusNotFound)
+	if err != nil {
+		return false, fmt.Errorf("Error occurred while getting snapshot repository: %v", err)
+	}
+	return res.StatusCode == http.StatusOK, nil
+}
+
+// CreateSnapshotRepository registers a shared file system snapshot repository
+// at the provided location. The location must be listed in the path.repo
+// setting of every node.
+func (c *Client) CreateSnapshotRepository(name string, location string) error {
+	body := map[string]interface{}{
+		"type": "fs",
+		"settings": map[string]interface{}{
+			"location": location,
+		},
+	}
+	res, err := c.client.PerformRequest(http.MethodPut, "/_snapshot/"+url.PathEscape(name), nil, body)
+	if err != nil {
+		return fmt.Errorf("Error occurred while creating snapshot repository: %v", err)
+	}
+	ack := &acknowledgedResponse{}
+	err = json.Unmarshal(res.Body, ack)
+	if err != nil {
+		return fmt.Errorf("Error occurred while creating snapshot repository: %v", err)
+	}
+	if !ack.Acknowledged {
+		return fmt.Errorf("Create snapshot repository request not acknowledged for `%s`", name)
+	}
+	return nil
+}
+
+// CreateSnapshot takes a snapshot of the provided indices and waits for it to
+// complete. The returned status reports any shards that failed.
+func (c *Client) CreateSnapshot(repository string, snapshot string, indices []string) (*deluge.SnapshotStatus, error) {
+	body := map[string]interface{}{
+		"indices":              strings.Join(indices, ","),
+		"include_global_state": false,
+	}
+	params := url.Values{}
+	params.Set("wait_for_completion", "true")
+	path := "/_snapshot/" + url.PathEscape(repository) + "/" + url.PathEscape(snapshot)
+	res, err := c.client.PerformRequest(http.MethodPut, path, params, body)
+	if err != nil {
+		return nil, fmt.Errorf("Error occurred while creating snapshot: %v", err)
+	}
+	created := &snapshotResponse{}
+	err = json.Unmarshal(res.Body, created)
+	if err != nil {
+		return nil, fmt.Errorf("Error occurred while creating snapshot: %v", err)
+	}
+	if created.Snapshot == nil {
+		return nil, fmt.Errorf("Create snapshot response missing snapshot `%s`", snapshot)
+	}
+	return created.status(repository), nil
+}
@@ -2,26 +2,123 @@ package elastic
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
 	"time"
 
 	"gopkg.in/olivere/elastic.v5"
+
+	"github.com/unchartedsoftware/deluge"
+	"github.com/unchartedsoftware/deluge/backoff"
 )
 
 // BulkRequest represents an elasticsearch bulk request.
 type BulkRequest struct {
 	service *elastic.BulkService
 	reqs    []elastic.BulkableRequest
+	failed  []*deluge.BulkItemError
+	backoff *backoff.Backoff
 	start   time.Time
 }
 
-// Add adds a bulkable request to the bulk payload.
+// Add adds a bulkable index request to the bulk payload.
 func (r *BulkRequest) Add(typ string, id string, source interface{}) {
-	req := elastic.NewBulkIndexRequest().Id(id).Type(typ).Doc(source)
+	r.AddItem(&deluge.BulkItem{
+		Operation: deluge.OperationIndex,
+		Type:      typ,
+		ID:        id,
+		Source:    source,
+	})
+}
+
+// AddItem adds a bulkable request for the operation of the item to the bulk
+// payload.
+func (r *BulkRequest) AddItem(item *deluge.BulkItem) {
+	var req elastic.BulkableRequest
+	switch item.Operation {
+	case deluge.OperationCreate:
+		req = newIndexRequest(item).OpType("create")
+	case deluge.OperationUpdate:
+		req = newUpdateRequest(item).Doc(rawDoc(item.Source))
+	case deluge.OperationUpsert:
+		req = newUpdateRequest(item).Doc(rawDoc(item.Source)).DocAsUpsert(true)
+	case deluge.OperationScript:
+		req = newUpdateRequest(item).Script(newScript(item.Source))
+	case deluge.OperationDelete:
+		req = newDeleteRequest(item)
+	default:
+		req = newIndexRequest(item)
+	}
 	r.service.Add(req)
 	r.reqs = append(r.reqs, req)
 }
 
+func newIndexRequest(item *deluge.BulkItem) *elastic.BulkIndexRequest {
+	req := elastic.NewBulkIndexRequest().Index(item.Index).Id(item.ID).Type(item.Type).Doc(item.Source)
+	meta := item.Metadata
+	if meta == nil {
+		return req
+	}
+	req.Routing(meta.Routing).VersionType(meta.VersionType).Pipeline(meta.Pipeline)
+	if meta.Version != nil {
+		req.Version(*meta.Version)
+	}
+	return req
+}
+
+func newUpdateRequest(item *deluge.BulkItem) *elastic.BulkUpdateRequest {
+	req := elastic.NewBulkUpdateRequest().Index(item.Index).Id(item.ID).Type(item.Type)
+	meta := item.Metadata
+	if meta == nil {
+		return req
+	}
+	req.Routing(meta.Routing).VersionType(meta.VersionType)
+	if meta.Version != nil {
+		req.Version(*meta.Version)
+	}
+	return req
+}
+
+func newDeleteRequest(item *deluge.BulkItem) *elastic.BulkDeleteRequest {
+	req := elastic.NewBulkDeleteRequest().Index(item.Index).Id(item.ID).Type(item.Type)
+	meta := item.Metadata
+	if meta == nil {
+		return req
+	}
+	req.Routing(meta.Routing).VersionType(meta.VersionType)
+	if meta.Version != nil {
+		req.Version(*meta.Version)
+	}
+	return req
+}
+
+func newScript(source interface{}) *elastic.Script {
+	script, ok := source.(*deluge.Script)
+	if !ok || script == nil {
+		return elastic.NewScript("")
+	}
+	s := elastic.NewScript(script.Source).Params(script.Params)
+	if script.Lang != "" {
+		s = s.Lang(script.Lang)
+	}
+	return s
+}
+
+// rawDoc returns pre-serialized partial documents as a json.RawMessage, so
+// that they are not encoded as JSON strings.
+func rawDoc(doc interface{}) interface{} {
+	switch d := doc.(type) {
+	case string:
+		return json.RawMessage(d)
+	case *string:
+		if d != nil {
+			return json.RawMessage(*d)
+		}
+	case []byte:
+		return json.RawMessage(d)
+	}
+	return doc
+}
+
 // EstimatedSizeInBytes returns the estimated size in bytes.
 func (r *BulkRequest) EstimatedSizeInBytes() int64 {
 	return r.service.EstimatedSizeInBytes()
@@ -37,23 +134,65 @@ func (r *BulkRequest) Took() uint64 {
 	return uint64((time.Since(r.start)).Nanoseconds()) / uint64(time.Millisecond)
 }
 
-// Send sends the bulk request and handles the response.
-func (r *BulkRequest) Send() (uint64, error) {
-	res, err := r.service.Do(context.Background())
-	if err != nil {
-		return 0, err
-	}
-	if res.Errors {
-		// find first error and return it
-		for index, items := range res.Items {
-			action, ok := items["index"]
-			if ok {
-				if action.Error != nil {
-					var src = r.reqs[index].String()
-					return uint64(res.Took), fmt.Errorf("%s: %s, %s", action.Error.Type, action.Error.Reason, src)
+// Send sends the bulk request and handles the response. Items rejected due
+// to back pressure are resent with an exponential backoff. Documents that were
+// permanently rejected do not fail the request, and are instead available
+// through Failed.
+func (r *BulkRequest) Send(ctx context.Context) (uint64, error) {
+	r.failed = nil
+	took := uint64(0)
+	// the positions of the items being sent within the original request
+	items := make([]int, len(r.reqs))
+	for index := range items {
+		items[index] = index
+	}
+	for attempt := 1; ; attempt++ {
+		res, err := r.service.Do(ctx)
+		if err != nil {
+			return took, err
+		}
+		took += uint64(res.Took)
+		var retries []int
+		if res.Errors {
+			// collect every failed item
+			for index, results := range res.Items {
+				for _, action := range results {
+					if action.Error == nil {
+						continue
+					}
+					item := items[index]
+					if attempt < r.backoff.MaxAttempts() &&
+						backoff.IsRetryable(action.Status, action.Error.Type) {
+						retries = append(retries, item)
+						continue
+					}
+					r.failed = append(r.failed, &deluge.BulkItemError{
+						Item:   item,
+						ID:     action.Id,
+						Status: action.Status,
+						Type:   action.Error.Type,
+						Reason: action.Error.Reason,
+						Action: r.reqs[item].String(),
+					})
 				}
 			}
 		}
+		if len(retries) == 0 {
+			return took, nil
+		}
+		// wait and then resend only the retryable items
+		err = r.backoff.Wait(ctx, attempt)
+		if err != nil {
+			return took, err
+		}
+		for _, item := range retries {
+			r.service.Add(r.reqs[item])
+		}
+		items = retries
 	}
-	return uint64(res.Took), nil
+}
+
+// Failed returns the documents that were rejected by the last Send.
+func (r *BulkRequest) Failed() []*deluge.BulkItemError {
+	return r.failed
 }
@@ -3,11 +3,13 @@ package elastic
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"gopkg.in/olivere/elastic.v5"
 
 	"github.com/unchartedsoftware/deluge"
+	"github.com/unchartedsoftware/deluge/backoff"
 	es "github.com/unchartedsoftware/deluge/input/elastic"
 )
 
@@ -115,7 +117,8 @@ var (
 
 // Client represents an elasticsearch client compatible with version 2.x.x.
 type Client struct {
-	client *elastic.Client
+	client  *elastic.Client
+	backoff *backoff.Backoff
 }
 
 // NewClient returns a new elasticsearch client.
@@ -125,7 +128,8 @@ func NewClient(options ...elastic.ClientOptionFunc) (*Client, error) {
 		return nil, err
 	}
 	return &Client{
-		client: client,
+		client:  client,
+		backoff: backoff.Default(),
 	}, nil
 }
 
@@ -133,10 +137,17 @@ func NewClient(options ...elastic.ClientOptionFunc) (*Client, error) {
 func (c *Client) NewBulkRequest(index string) deluge.BulkRequest {
 	return &BulkRequest{
 		service: c.client.Bulk().Index(index),
+		backoff: c.backoff,
 		start:   time.Now(),
 	}
 }
 
+// SetBulkBackoff sets the backoff used when retrying bulk items that were
+// rejected due to back pressure.
+func (c *Client) SetBulkBackoff(b *backoff.Backoff) {
+	c.backoff = b
+}
+
 // IndexExists returns whether or not the specified index exists.
 func (c *Client) IndexExists(index string) (bool, error) {
 	return c.client.IndexExists(index).Do(context.Background())
@@ -154,10 +165,17 @@ func (c *Client) DeleteIndex(index string) error {
 	return nil
 }
 
-// CreateIndex creates the specified index with the provided mapping.
-func (c *Client) CreateIndex(index string, mapping string) error {
+// CreateIndex creates the specified index with the provided mapping. The
+// provided settings take precedence over the default settings.
+func (c *Client) CreateIndex(index string, mapping string, settings string) error {
 	// prepare the create index body
-	body := fmt.Sprintf("{\"mappings\":%s,\"settings\":{\"number_of_replicas\":0}}", mapping)
+	merged, err := deluge.MergeSettings(settings, map[string]interface{}{
+		"index.number_of_replicas": 0,
+	})
+	if err != nil {
+		return fmt.Errorf("Error occurred while creating index: %v", err)
+	}
+	body := fmt.Sprintf("{\"mappings\":%s,\"settings\":%s}", mapping, merged)
 	res, err := c.client.CreateIndex(index).Body(body).Do(context.Background())
 	if err != nil {
 		return fmt.Errorf("Error occurred while creating index: %v", err)
@@ -193,6 +211,67 @@ func (c *Client) EnableReplicas(index string, numReplicas int) error {
 	return nil
 }
 
+// PutSettings updates the dynamic settings of the specified index with the
+// provided JSON encoded settings.
+func (c *Client) PutSettings(index string, settings string) error {
+	res, err := c.client.IndexPutSettings(index).BodyString(settings).Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("Error occurred while updating settings for index: %v", err)
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("Put settings request not acknowledged for index `%s`", index)
+	}
+	return nil
+}
+
+// Refresh refreshes the specified index, making all ingested documents
+// visible to search.
+func (c *Client) Refresh(index string) error {
+	_, err := c.client.Refresh(index).Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("Error occurred while refreshing index: %v", err)
+	}
+	return nil
+}
+
+// Count returns the number of documents in the specified index, as visible
+// to search.
+func (c *Client) Count(index string) (int64, error) {
+	count, err := c.client.Count(index).Do(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("Error occurred while counting documents of index: %v", err)
+	}
+	return count, nil
+}
+
+// ForceMerge merges the segments of the specified index down to the provided
+// maximum number of segments.
+func (c *Client) ForceMerge(index string, maxNumSegments int) error {
+	_, err := c.client.Forcemerge(index).MaxNumSegments(maxNumSegments).Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("Error occurred while force merging index: %v", err)
+	}
+	return nil
+}
+
+// WaitForGreen waits until the health of the specified index is green, or
+// returns an error once the timeout expires.
+func (c *Client) WaitForGreen(index string, timeout time.Duration) error {
+	res, err := c.client.ClusterHealth().
+		Index(index).
+		WaitForGreenStatus().
+		Timeout(fmt.Sprintf("%dms", timeout.Milliseconds())).
+		Do(context.Background())
+	// the request times out with a 408 status code
+	if elastic.IsTimeout(err) || (err == nil && (res.TimedOut || res.Status != "green")) {
+		return fmt.Errorf("Index `%s` did not reach green health within %v", index, timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("Error occurred while waiting for green health of index: %v", err)
+	}
+	return nil
+}
+
 // GetIndexSummary returns an index summary struct.
 func (c *Client) GetIndexSummary(index string) (es.IndexSummary, error) {
 	// get stats about the index
@@ -266,3 +345,45 @@ func (c *Client) SetBlockWrite(index string, blockWrite bool) error {
 	}
 	return nil
 }
+
+// ListIndices returns the names of all indices beginning with the provided
+// prefix.
+func (c *Client) ListIndices(prefix string) ([]string, error) {
+	names, err := c.client.IndexNames()
+	if err != nil {
+		return nil, fmt.Errorf("Error occurred while listing indices: %v", err)
+	}
+	var indices []string
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			indices = append(indices, name)
+		}
+	}
+	return indices, nil
+}
+
+// GetAliasIndices returns the names of all indices the alias points to.
+func (c *Client) GetAliasIndices(alias string) ([]string, error) {
+	res, err := c.client.Aliases().Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("Error occurred while getting alias `%s`: %v", alias, err)
+	}
+	return res.IndicesByAlias(alias), nil
+}
+
+// UpdateAlias atomically points the alias to the provided index and removes
+// it from the provided previous indices.
+func (c *Client) UpdateAlias(alias string, index string, previous []string) error {
+	service := c.client.Alias().Add(index, alias)
+	for _, prev := range previous {
+		service = service.Remove(prev, alias)
+	}
+	res, err := service.Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("Error occurred while updating alias `%s`: %v", alias, err)
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("Update alias request not acknowledged for alias `%s`", alias)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package elastic
+
+import (
+	"testing"
+
+	"github.com/unchartedsoftware/deluge/elastic/estest"
+	"github.com/unchartedsoftware/deluge/elastic/estest/clienttest"
+)
+
+func TestClient(t *testing.T) {
+	clienttest.Run(t, &clienttest.Config{
+		NewClient: func(url string) (clienttest.Client, error) {
+			return NewClient(SetURL(url))
+		},
+		Options: []estest.OptionFunc{
+			estest.SetVersion("5.6.16"),
+		},
+		Type:      "datum",
+		Pipelines: true,
+		Templates: clienttest.TemplatesLegacy,
+	})
+}
@@ -0,0 +1,50 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/unchartedsoftware/deluge"
+)
+
+// PutTemplate installs the provided legacy index template. Templates in 5.x
+// only support a single index pattern.
+func (c *Client) PutTemplate(template *deluge.IndexTemplate) error {
+	if len(template.Patterns) != 1 {
+		return fmt.Errorf("Index template `%s` must have exactly one index pattern", template.Name)
+	}
+	body := map[string]interface{}{
+		"template": template.Patterns[0],
+		"order":    template.Priority,
+	}
+	if template.Settings != "" {
+		body["settings"] = json.RawMessage(template.Settings)
+	}
+	if template.Mapping != "" {
+		body["mappings"] = json.RawMessage(template.Mapping)
+	}
+	res, err := c.client.IndexPutTemplate(template.Name).BodyJson(body).Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("Error occurred while putting index template: %v", err)
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("Put index template request not acknowledged for `%s`", template.Name)
+	}
+	return nil
+}
+
+// PutIndexTemplate is not supported in 5.x, use PutTemplate instead.
+func (c *Client) PutIndexTemplate(template *deluge.IndexTemplate) error {
+	return fmt.Errorf("Composable index templates are not supported by elasticsearch 5.x")
+}
+
+// PutComponentTemplate is not supported in 5.x.
+func (c *Client) PutComponentTemplate(name string, template string) error {
+	return fmt.Errorf("Component templates are not supported by elasticsearch 5.x")
+}
+
+// PutLifecyclePolicy is not supported in 5.x.
+func (c *Client) PutLifecyclePolicy(name string, policy string) error {
+	return fmt.Errorf("Index lifecycle policies are not supported by elasticsearch 5.x")
+}
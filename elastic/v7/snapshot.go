@@ -0,0 +1,77 @@
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+
+	"github.com/unchartedsoftware/deluge"
+)
+
+// SnapshotRepositoryExists returns whether or not the specified snapshot
+// repository is registered.
+func (c *Client) SnapshotRepositoryExists(name string) (bool, error) {
+	_, err := c.client.SnapshotGetRepository(name).Do(context.Background())
+	if elastic.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("Error occurred while getting snapshot repository: %v", err)
+	}
+	return true, nil
+}
+
+// CreateSnapshotRepository registers a shared file system snapshot repository
+// at the provided location. The location must be listed in the path.repo
+// setting of every node.
+func (c *Client) CreateSnapshotRepository(name string, location string) error {
+	res, err := c.client.SnapshotCreateRepository(name).
+		Type("fs").
+		Setting("location", location).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("Error occurred while creating snapshot repository: %v", err)
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("Create snapshot repository request not acknowledged for `%s`", name)
+	}
+	return nil
+}
+
+// CreateSnapshot takes a snapshot of the provided indices and waits for it to
+// complete. The returned status reports any shards that failed.
+func (c *Client) CreateSnapshot(repository string, snapshot string, indices []string) (*deluge.SnapshotStatus, error) {
+	res, err := c.client.SnapshotCreate(repository, snapshot).
+		WaitForCompletion(true).
+		BodyJson(map[string]interface{}{
+			"indices":              strings.Join(indices, ","),
+			"include_global_state": false,
+		}).
+		Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("Error occurred while creating snapshot: %v", err)
+	}
+	s := res.Snapshot
+	if s == nil {
+		return nil, fmt.Errorf("Create snapshot response missing snapshot `%s`", snapshot)
+	}
+	status := &deluge.SnapshotStatus{
+		Repository: repository,
+		Snapshot:   s.Snapshot,
+		State:      s.State,
+		Indices:    s.Indices,
+		Duration:   time.Duration(s.DurationInMillis) * time.Millisecond,
+	}
+	if s.Shards != nil {
+		status.TotalShards = s.Shards.Total
+		status.FailedShards = s.Shards.Failed
+	}
+	for _, failure := range s.Failures {
+		status.Failures = append(status.Failures,
+			fmt.Sprintf("[%s][%d] %s", failure.Index, failure.ShardID, failure.Reason))
+	}
+	return status, nil
+}
@@ -13,5 +13,5 @@ func (i *IndexSummary) ByteSize() uint64 {
 
 // NumDocs returns the number of documents in the index.
 func (i *IndexSummary) NumDocs() uint64 {
-	return i.byteSize
+	return i.numDocs
 }
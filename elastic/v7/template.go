@@ -0,0 +1,105 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/olivere/elastic/v7"
+
+	"github.com/unchartedsoftware/deluge"
+)
+
+type acknowledgedResponse struct {
+	Acknowledged bool `json:"acknowledged"`
+}
+
+// templateBody returns the settings and mappings of the template.
+func templateBody(template *deluge.IndexTemplate) map[string]interface{} {
+	body := make(map[string]interface{})
+	if template.Settings != "" {
+		body["settings"] = json.RawMessage(template.Settings)
+	}
+	if template.Mapping != "" {
+		body["mappings"] = json.RawMessage(template.Mapping)
+	}
+	return body
+}
+
+// PutTemplate installs the provided legacy index template.
+func (c *Client) PutTemplate(template *deluge.IndexTemplate) error {
+	body := templateBody(template)
+	body["index_patterns"] = template.Patterns
+	body["order"] = template.Priority
+	res, err := c.client.IndexPutTemplate(template.Name).BodyJson(body).Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("Error occurred while putting index template: %v", err)
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("Put index template request not acknowledged for `%s`", template.Name)
+	}
+	return nil
+}
+
+// PutIndexTemplate installs the provided composable index template. Composable
+// templates require elasticsearch 7.8 or higher.
+func (c *Client) PutIndexTemplate(template *deluge.IndexTemplate) error {
+	body := map[string]interface{}{
+		"index_patterns": template.Patterns,
+		"priority":       template.Priority,
+		"template":       templateBody(template),
+	}
+	if len(template.ComposedOf) > 0 {
+		body["composed_of"] = template.ComposedOf
+	}
+	err := c.put("/_index_template/"+url.PathEscape(template.Name), body)
+	if err != nil {
+		return fmt.Errorf("Error occurred while putting index template: %v", err)
+	}
+	return nil
+}
+
+// PutComponentTemplate installs the provided component template. Component
+// templates require elasticsearch 7.8 or higher.
+func (c *Client) PutComponentTemplate(name string, template string) error {
+	err := c.put("/_component_template/"+url.PathEscape(name), template)
+	if err != nil {
+		return fmt.Errorf("Error occurred while putting component template: %v", err)
+	}
+	return nil
+}
+
+// PutLifecyclePolicy installs the provided index lifecycle policy.
+func (c *Client) PutLifecyclePolicy(name string, policy string) error {
+	res, err := c.client.XPackIlmPutLifecycle().Policy(name).BodyString(policy).Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("Error occurred while putting lifecycle policy: %v", err)
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("Put lifecycle policy request not acknowledged for `%s`", name)
+	}
+	return nil
+}
+
+// put sends a PUT request for endpoints without a dedicated service.
+func (c *Client) put(path string, body interface{}) error {
+	res, err := c.client.PerformRequest(context.Background(), elastic.PerformRequestOptions{
+		Method: http.MethodPut,
+		Path:   path,
+		Body:   body,
+	})
+	if err != nil {
+		return err
+	}
+	ack := &acknowledgedResponse{}
+	err = json.Unmarshal(res.Body, ack)
+	if err != nil {
+		return err
+	}
+	if !ack.Acknowledged {
+		return fmt.Errorf("Request not acknowledged for `%s`", path)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package elastic
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+
+	"github.com/unchartedsoftware/deluge/elastic/rest"
+)
+
+// ClientOptionFunc is a function that configures the underlying
+// elasticsearch client. It is used in NewClient.
+type ClientOptionFunc func(*elasticsearch.Config) error
+
+// SetURL defines the URL endpoints of the Elasticsearch nodes.
+func SetURL(urls ...string) ClientOptionFunc {
+	return func(c *elasticsearch.Config) error {
+		c.Addresses = urls
+		return nil
+	}
+}
+
+// SetCloudID sets the endpoint for the Elastic Service.
+func SetCloudID(cloudID string) ClientOptionFunc {
+	return func(c *elasticsearch.Config) error {
+		c.CloudID = cloudID
+		return nil
+	}
+}
+
+// SetBasicAuth can be used to specify the HTTP Basic Auth credentials to
+// use when making HTTP requests to Elasticsearch.
+func SetBasicAuth(username string, password string) ClientOptionFunc {
+	return func(c *elasticsearch.Config) error {
+		c.Username = username
+		c.Password = password
+		return nil
+	}
+}
+
+// SetAPIKey sets the base64-encoded API key used for authorization. If set,
+// it overrides basic auth and bearer token credentials.
+func SetAPIKey(apiKey string) ClientOptionFunc {
+	return func(c *elasticsearch.Config) error {
+		c.APIKey = apiKey
+		return nil
+	}
+}
+
+// SetBearerToken sets the bearer token used for authorization, ex. a service
+// account token. If set, it overrides basic auth credentials.
+func SetBearerToken(token string) ClientOptionFunc {
+	return func(c *elasticsearch.Config) error {
+		c.ServiceToken = token
+		return nil
+	}
+}
+
+// SetCertificateFingerprint sets the SHA256 hex fingerprint of the CA
+// certificate given by Elasticsearch on first launch.
+func SetCertificateFingerprint(fingerprint string) ClientOptionFunc {
+	return func(c *elasticsearch.Config) error {
+		c.CertificateFingerprint = fingerprint
+		return nil
+	}
+}
+
+// SetCACert sets the PEM-encoded certificate authorities to trust. It is only
+// valid when no transport, or an *http.Transport, is specified.
+func SetCACert(cert []byte) ClientOptionFunc {
+	return func(c *elasticsearch.Config) error {
+		c.CACert = cert
+		return nil
+	}
+}
+
+// SetHTTPClient can be used to specify the http.Client to use when making
+// HTTP requests to Elasticsearch. Only the transport of the client is used.
+func SetHTTPClient(client *http.Client) ClientOptionFunc {
+	return func(c *elasticsearch.Config) error {
+		c.Transport = client.Transport
+		return nil
+	}
+}
+
+// SetTransport sets the HTTP transport to use when making HTTP requests to
+// Elasticsearch.
+func SetTransport(transport http.RoundTripper) ClientOptionFunc {
+	return func(c *elasticsearch.Config) error {
+		c.Transport = transport
+		return nil
+	}
+}
+
+// SetHeader sets a global HTTP header sent with every request.
+func SetHeader(name string, value string) ClientOptionFunc {
+	return func(c *elasticsearch.Config) error {
+		if c.Header == nil {
+			c.Header = http.Header{}
+		}
+		c.Header.Set(name, value)
+		return nil
+	}
+}
+
+// SetSniff enables or disables discovering the cluster nodes on startup
+// (disabled by default).
+func SetSniff(sniff bool) ClientOptionFunc {
+	return func(c *elasticsearch.Config) error {
+		c.DiscoverNodesOnStart = sniff
+		return nil
+	}
+}
+
+// SetSnifferInterval sets the interval between two node discoveries. The
+// default is to not periodically discover nodes.
+func SetSnifferInterval(interval time.Duration) ClientOptionFunc {
+	return func(c *elasticsearch.Config) error {
+		c.DiscoverNodesInterval = interval
+		return nil
+	}
+}
+
+// SetMaxRetries sets the maximum number of retries before giving up when
+// performing a HTTP request to Elasticsearch.
+func SetMaxRetries(maxRetries int) ClientOptionFunc {
+	return func(c *elasticsearch.Config) error {
+		c.MaxRetries = maxRetries
+		return nil
+	}
+}
+
+// SetRetryOnStatus sets the HTTP status codes that are retried. The default
+// is 502, 503 and 504.
+func SetRetryOnStatus(statuses ...int) ClientOptionFunc {
+	return func(c *elasticsearch.Config) error {
+		c.RetryOnStatus = statuses
+		return nil
+	}
+}
+
+// SetGzip enables or disables gzip compression of request bodies (disabled by
+// default).
+func SetGzip(enabled bool) ClientOptionFunc {
+	return func(c *elasticsearch.Config) error {
+		c.CompressRequestBody = enabled
+		return nil
+	}
+}
+
+// Client represents an elasticsearch client compatible with version 8.x.x.
+type Client struct {
+	*rest.TemplateClient
+}
+
+// NewClient returns a new elasticsearch client.
+func NewClient(shards uint16, options ...ClientOptionFunc) (*Client, error) {
+	config := elasticsearch.Config{}
+	for _, option := range options {
+		if err := option(&config); err != nil {
+			return nil, err
+		}
+	}
+	client, err := elasticsearch.NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+	c, err := rest.NewClient(client, shards)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		TemplateClient: &rest.TemplateClient{
+			Client: c,
+		},
+	}, nil
+}
@@ -0,0 +1,23 @@
+package elastic
+
+import (
+	"testing"
+
+	"github.com/unchartedsoftware/deluge/elastic/estest"
+	"github.com/unchartedsoftware/deluge/elastic/estest/clienttest"
+)
+
+func TestClient(t *testing.T) {
+	clienttest.Run(t, &clienttest.Config{
+		NewClient: func(url string) (clienttest.Client, error) {
+			return NewClient(2, SetURL(url))
+		},
+		Options: []estest.OptionFunc{
+			estest.SetVersion("8.4.0"),
+		},
+		Shards:    2,
+		Pipelines: true,
+		SeqNo:     true,
+		Templates: clienttest.TemplatesComposable,
+	})
+}
@@ -1,6 +1,7 @@
 package equalizer
 
 import (
+	"context"
 	"sync"
 	"time"
 )
@@ -11,109 +12,134 @@ const (
 )
 
 // Equalizer represents an equalzier to apply backpressure to bulk requests.
-var (
+type Equalizer struct {
 	ready          chan error
 	waitGroup      *sync.WaitGroup
 	rates          []uint64
+	ratesMutex     *sync.Mutex
 	maxNumRequests int
-)
+}
 
 // Request represents a bulk request and its generation time.
 type Request interface {
 	Took() uint64
-	Send() (uint64, error)
+	Send(context.Context) (uint64, error)
 }
 
 // CallbackFunc represents an simple callback function to be executed after a
-// successful send.
-type CallbackFunc func(error)
+// send. The returned error, if any, replaces the error of the send and is
+// propagated to subsequent calls to Send.
+type CallbackFunc func(error) error
 
-// Open initiializes the equalizer and readies it for sending requests.
-func Open(size int) {
-	// get max number of requests
-	maxNumRequests = size
-	// initialize channels
-	ready = make(chan error)
-	waitGroup = new(sync.WaitGroup)
+// Open initiializes a new equalizer and readies it for sending requests.
+func Open(size int) *Equalizer {
+	e := &Equalizer{
+		// get max number of requests
+		maxNumRequests: size,
+		// initialize channels
+		ready:      make(chan error),
+		waitGroup:  new(sync.WaitGroup),
+		ratesMutex: new(sync.Mutex),
+	}
+	e.waitGroup.Add(1)
 	go func() {
-		waitGroup.Add(1)
 		// send as many ready messages as there are concurrent requests
-		for i := 0; i < maxNumRequests; i++ {
-			ready <- nil
+		for i := 0; i < e.maxNumRequests; i++ {
+			e.ready <- nil
 		}
 	}()
+	return e
 }
 
-func getAvg() float64 {
+func (e *Equalizer) getAvg() float64 {
+	e.ratesMutex.Lock()
+	defer e.ratesMutex.Unlock()
 	total := uint64(0)
-	for _, ms := range rates {
+	for _, ms := range e.rates {
 		total += ms
 	}
-	return float64(total) / float64(len(rates))
+	return float64(total) / float64(len(e.rates))
 }
 
-func measure(ms uint64) {
-	rates = append(rates, ms)
-	if len(rates) > maxNumRates {
+func (e *Equalizer) measure(ms uint64) {
+	e.ratesMutex.Lock()
+	defer e.ratesMutex.Unlock()
+	e.rates = append(e.rates, ms)
+	if len(e.rates) > maxNumRates {
 		// if past max rates, pop oldest one off
-		rates = rates[1:]
+		e.rates = e.rates[1:]
 	}
 }
 
-func throttle(took uint64) {
+func (e *Equalizer) throttle(ctx context.Context, took uint64) {
 	// get difference between the time it took to generate the payload vs
 	// the time it takes ES to ingest
-	delta := getAvg() - float64(took)
-	// wait the difference if it is positive
+	delta := e.getAvg() - float64(took)
+	// wait the difference if it is positive, or until cancelled
 	if delta > 0 {
-		time.Sleep(time.Millisecond * time.Duration(delta))
+		select {
+		case <-time.After(time.Millisecond * time.Duration(delta)):
+		case <-ctx.Done():
+		}
 	}
 }
 
-func forwardRequest(req Request, reqTook uint64, fn CallbackFunc) {
-	throttle(reqTook)
-	took, err := req.Send()
+func (e *Equalizer) forwardRequest(ctx context.Context, req Request, reqTook uint64, fn CallbackFunc) {
+	e.throttle(ctx, reqTook)
+	took, err := req.Send(ctx)
 	if fn != nil {
-		fn(err)
+		err = fn(err)
 	}
-	measure(took)
-	ready <- err
-	waitGroup.Done()
+	e.measure(took)
+	e.ready <- err
+	e.waitGroup.Done()
 }
 
 // Send dispatches a request through the equalizer. This call will wait on
 // pending requests, and if said pending requests results in an error, will
-// return it.
-func Send(req Request, fn CallbackFunc) error {
+// return it. If the context is cancelled while waiting, the context error is
+// returned and the request is not sent.
+func (e *Equalizer) Send(ctx context.Context, req Request, fn CallbackFunc) error {
 	took := req.Took()
-	err := <-ready
-	if err != nil {
-		fn(err) // we need to call this to release the waitgroup
-		return err
+	select {
+	case err := <-e.ready:
+		if err != nil {
+			// hand the slot back so that Close can still drain it
+			e.waitGroup.Add(1)
+			go func() {
+				e.ready <- err
+				e.waitGroup.Done()
+			}()
+			fn(err) // we need to call this to release the waitgroup
+			return err
+		}
+	case <-ctx.Done():
+		fn(ctx.Err()) // we need to call this to release the waitgroup
+		return ctx.Err()
 	}
-	waitGroup.Add(1)
-	go forwardRequest(req, took, fn)
+	e.waitGroup.Add(1)
+	go e.forwardRequest(ctx, req, took, fn)
 	return nil
 }
 
 // Close disables the equalizer so that it no longer listens to any incoming bulk requests.
-func Close() []error {
+func (e *Equalizer) Close() []error {
 	// at this point any requests will be blocked waiting for the eq to read
 	// from the ready channel, so lets grab all these right now so the Equalizer
 	// can close
 	var errs []error
 	go func() {
-		for i := 0; i < maxNumRequests; i++ {
-			err := <-ready
+		for i := 0; i < e.maxNumRequests; i++ {
+			err := <-e.ready
 			if err != nil {
 				errs = append(errs, err)
 			}
 		}
-		waitGroup.Done()
+		e.waitGroup.Done()
 	}()
 	// ensure there are no pending responses
-	waitGroup.Wait()
+	e.waitGroup.Wait()
 	// safe to close ready channel now
-	close(ready)
+	close(e.ready)
 	return errs
 }
@@ -0,0 +1,65 @@
+package deluge
+
+import (
+	"fmt"
+)
+
+// CancelledError is returned by IngestContext when the provided context is
+// cancelled or its deadline is exceeded before the ingest completes.
+type CancelledError struct {
+	// Err is the underlying context error.
+	Err error
+	// NumCommitted is the number of documents that were successfully
+	// committed to elasticsearch before cancellation.
+	NumCommitted int64
+}
+
+// Error returns the error message.
+func (e *CancelledError) Error() string {
+	return fmt.Sprintf("Ingest cancelled after %d documents were committed: %v",
+		e.NumCommitted,
+		e.Err)
+}
+
+// Unwrap returns the underlying context error.
+func (e *CancelledError) Unwrap() error {
+	return e.Err
+}
+
+// BulkItemError represents a single document that was rejected within an
+// otherwise successful bulk request.
+type BulkItemError struct {
+	// Item is the position of the document within the bulk request.
+	Item int
+	// ID is the id of the document.
+	ID string
+	// Status is the HTTP status code returned for the document.
+	Status int
+	// Type is the elasticsearch error type, ex. `mapper_parsing_exception`.
+	Type string
+	// Reason is the elasticsearch error reason.
+	Reason string
+	// Action is the serialized bulk action and document.
+	Action string
+	// Source is the name of the input source the document originated from.
+	Source string
+}
+
+// Error returns the error message.
+func (e *BulkItemError) Error() string {
+	if e.Source != "" {
+		return fmt.Sprintf("%s: %s, id: `%s`, status: %d, source: `%s`, %s",
+			e.Type,
+			e.Reason,
+			e.ID,
+			e.Status,
+			e.Source,
+			e.Action)
+	}
+	return fmt.Sprintf("%s: %s, id: `%s`, status: %d, %s",
+		e.Type,
+		e.Reason,
+		e.ID,
+		e.Status,
+		e.Action)
+}
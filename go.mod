@@ -1,19 +1,20 @@
 module github.com/unchartedsoftware/deluge
 
-go 1.12
+go 1.13
 
 require (
 	github.com/colinmarc/hdfs v1.1.3
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/elastic/go-elasticsearch/v8 v8.4.0
 	github.com/kr/pretty v0.2.0 // indirect
 	github.com/mattn/go-colorable v0.1.1 // indirect
 	github.com/mattn/go-isatty v0.0.7 // indirect
 	github.com/mgutz/ansi v0.0.0-20170206155736-9520e82c474b // indirect
 	github.com/olivere/elastic/v7 v7.0.10
+	github.com/opensearch-project/opensearch-go v1.1.0
 	github.com/stretchr/objx v0.1.1 // indirect
 	github.com/unchartedsoftware/plog v0.0.0-20170413154239-34d2bbd3c0a9
 	golang.org/x/sync v0.0.0-20190911185100-cd5d95a43a6e // indirect
-	golang.org/x/sys v0.0.0-20200124204421-9fbb57f87de9 // indirect
 	gopkg.in/olivere/elastic.v3 v3.0.75
 	gopkg.in/olivere/elastic.v5 v5.0.84
 )
@@ -6,13 +6,21 @@ import (
 	"compress/flate"
 	"compress/gzip"
 	"compress/zlib"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	log "github.com/unchartedsoftware/plog"
 
+	"github.com/unchartedsoftware/deluge/checkpoint"
+	"github.com/unchartedsoftware/deluge/deadletter"
 	"github.com/unchartedsoftware/deluge/equalizer"
+	"github.com/unchartedsoftware/deluge/input"
 	"github.com/unchartedsoftware/deluge/pool"
 	"github.com/unchartedsoftware/deluge/progress"
 	"github.com/unchartedsoftware/deluge/threshold"
@@ -32,6 +40,17 @@ const (
 	defaultUpdateMapping        = false
 	defaultReadOnly             = false
 	defaultBlockWrite           = false
+	defaultResume               = false
+	defaultRetainIndices        = -1
+	defaultDryRun               = false
+	defaultTuneSettings         = false
+	defaultRefresh              = false
+	defaultForceMerge           = 0
+	defaultWaitForGreen         = 0
+	defaultVerify               = false
+	defaultVerifyTolerance      = 0.0
+	aliasTimestampFormat        = "20060102150405"
+	snapshotTimestampFormat     = "20060102150405.000000000"
 )
 
 // Ingestor is an Elasticsearch ingestor client. Create one by calling
@@ -53,6 +72,36 @@ type Ingestor struct {
 	readOnly             bool
 	blockWrite           bool
 	bulkSizeOptimiser    Optimiser
+	checkpointStore      checkpoint.Store
+	resume               bool
+	checkpointTracker    *checkpoint.Tracker
+	deadLetter           deadletter.Sink
+	dryRun               bool
+	dryRunSummary        *DryRunSummary
+	alias                string
+	retainIndices        int
+	indexTemplate        *IndexTemplate
+	componentTemplates   []*namedBody
+	lifecyclePolicy      *namedBody
+	indexSettings        string
+	tuneSettings         bool
+	refresh              bool
+	forceMerge           int
+	waitForGreen         time.Duration
+	snapshotRepository   string
+	snapshotLocation     string
+	snapshotName         string
+	snapshotStatus       *SnapshotStatus
+	verify               bool
+	verifyTolerance      float64
+	verifier             *verifier
+	verifySummaries      []*VerifySummary
+	target               string
+	indices              map[string]*documentIndex
+	indexMutex           *sync.Mutex
+	equalizer            *equalizer.Equalizer
+	errTracker           *threshold.Tracker
+	progress             *progress.Progress
 	mutex                *sync.RWMutex
 	callbackWG           *sync.WaitGroup
 }
@@ -72,7 +121,18 @@ func NewIngestor(options ...IngestorOptionFunc) (*Ingestor, error) {
 		updateMapping:        defaultUpdateMapping,
 		readOnly:             defaultReadOnly,
 		blockWrite:           defaultBlockWrite,
+		resume:               defaultResume,
+		retainIndices:        defaultRetainIndices,
+		dryRun:               defaultDryRun,
+		tuneSettings:         defaultTuneSettings,
+		refresh:              defaultRefresh,
+		forceMerge:           defaultForceMerge,
+		waitForGreen:         defaultWaitForGreen,
+		verify:               defaultVerify,
+		verifyTolerance:      defaultVerifyTolerance,
+		errTracker:           threshold.NewTracker(defaultThreshold),
 		mutex:                &sync.RWMutex{},
+		indexMutex:           &sync.Mutex{},
 		callbackWG:           &sync.WaitGroup{},
 	}
 	// run the options through it
@@ -84,17 +144,17 @@ func NewIngestor(options ...IngestorOptionFunc) (*Ingestor, error) {
 	return ingestor, nil
 }
 
-func (i *Ingestor) prepareIndex() error {
+func (i *Ingestor) prepareIndex(index string, clearExisting bool) error {
 	// check if index exists
-	indexExists, err := i.client.IndexExists(i.index)
+	indexExists, err := i.client.IndexExists(index)
 	if err != nil {
 		return err
 	}
 	// if index exists
-	if indexExists && i.clearExisting {
+	if indexExists && clearExisting {
 		// send the delete index request
-		log.Infof("Deleting existing index `%s`", i.index)
-		err := i.client.DeleteIndex(i.index)
+		log.Infof("Deleting existing index `%s`", index)
+		err := i.client.DeleteIndex(index)
 		if err != nil {
 			return fmt.Errorf("Error occurred while deleting index: %v", err)
 		}
@@ -115,33 +175,397 @@ func (i *Ingestor) prepareIndex() error {
 		return err
 	}
 	// if index does not exist at this point, create it
-	if !indexExists || i.clearExisting {
+	if !indexExists || clearExisting {
+		settings, err := i.createSettings()
+		if err != nil {
+			return err
+		}
 		// send create index request
-		log.Infof("Creating index `%s`", i.index)
-		err := i.client.CreateIndex(i.index, mapping)
+		log.Infof("Creating index `%s`", index)
+		err = i.client.CreateIndex(index, mapping, settings)
 		if err != nil {
 			return fmt.Errorf("Error occurred while creating index: %v", err)
 		}
-	} else if i.updateMapping {
+		return nil
+	}
+	if i.updateMapping {
 		// send put mapping request
-		log.Infof("Putting mapping `%s`", i.index)
-		err := i.client.PutMapping(i.index, typ, mapping)
+		log.Infof("Putting mapping `%s`", index)
+		err := i.client.PutMapping(index, typ, mapping)
 		if err != nil {
 			return fmt.Errorf("Error occurred while updating mapping for index: %v", err)
 		}
 	}
+	if i.tuneSettings {
+		// tune the existing index for the duration of the ingest
+		settings, err := json.Marshal(tunedSettings)
+		if err != nil {
+			return err
+		}
+		log.Infof("Tuning settings for index `%s`", index)
+		err = i.client.PutSettings(index, string(settings))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createSettings returns the settings for created indices, which are the
+// provided index settings with replicas disabled and, if enabled, the tuned
+// settings applied.
+func (i *Ingestor) createSettings() (string, error) {
+	settings, err := FlattenSettings(i.indexSettings)
+	if err != nil {
+		return "", err
+	}
+	// replicas are enabled once the ingest completes
+	settings["index.number_of_replicas"] = 0
+	if i.tuneSettings {
+		for key, value := range tunedSettings {
+			settings[key] = value
+		}
+	}
+	bytes, err := json.Marshal(settings)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// restoreSettings restores the tuned settings of the index to the values of
+// the provided index settings, or to their defaults if unset.
+func (i *Ingestor) restoreSettings(index string) error {
+	settings, err := FlattenSettings(i.indexSettings)
+	if err != nil {
+		return err
+	}
+	restored := make(map[string]interface{})
+	for key := range tunedSettings {
+		// a null value resets the setting to its default
+		restored[key] = settings[key]
+	}
+	bytes, err := json.Marshal(restored)
+	if err != nil {
+		return err
+	}
+	log.Infof("Restoring settings for index `%s`", index)
+	return i.client.PutSettings(index, string(bytes))
+}
+
+// restoreTouchedSettings restores the tuned settings of every touched index
+// of an ingest that did not complete. Failures are logged, as the error that
+// ended the ingest takes precedence.
+func (i *Ingestor) restoreTouchedSettings() {
+	for _, index := range i.touchedIndices() {
+		if err := i.restoreSettings(index); err != nil {
+			log.Errorf("Error occurred while restoring settings for index `%s`: %v", index, err)
+		}
+	}
+}
+
+func (i *Ingestor) prepareTemplates() error {
+	if i.indexTemplate == nil && len(i.componentTemplates) == 0 && i.lifecyclePolicy == nil {
+		return nil
+	}
+	client, ok := i.client.(TemplateClient)
+	if !ok {
+		return fmt.Errorf("Ingestor Elasticsearch client does not support index templates")
+	}
+	// component templates must exist before the index templates composed of them
+	for _, component := range i.componentTemplates {
+		log.Infof("Putting component template `%s`", component.name)
+		err := client.PutComponentTemplate(component.name, component.body)
+		if err != nil {
+			return err
+		}
+	}
+	if i.lifecyclePolicy != nil {
+		log.Infof("Putting lifecycle policy `%s`", i.lifecyclePolicy.name)
+		err := client.PutLifecyclePolicy(i.lifecyclePolicy.name, i.lifecyclePolicy.body)
+		if err != nil {
+			return err
+		}
+	}
+	if i.indexTemplate == nil {
+		return nil
+	}
+	// copy the template to avoid modifying the option
+	template := *i.indexTemplate
+	if template.Mapping == "" {
+		// default to the document mapping
+		document, err := i.documentCtor()
+		if err != nil {
+			return err
+		}
+		template.Mapping, err = document.GetMapping()
+		if err != nil {
+			return err
+		}
+	}
+	if i.lifecyclePolicy != nil {
+		// assign the policy to indices created from the template
+		settings, err := setDefaultSetting(template.Settings, lifecycleSetting, i.lifecyclePolicy.name)
+		if err != nil {
+			return err
+		}
+		template.Settings = settings
+	}
+	log.Infof("Putting index template `%s`", template.Name)
+	if template.Legacy {
+		return client.PutTemplate(&template)
+	}
+	return client.PutIndexTemplate(&template)
+}
+
+// documentIndex represents an index declared by documents. It is prepared
+// once, by the first worker to encounter it.
+type documentIndex struct {
+	once sync.Once
+	err  error
+}
+
+// indexError represents an error that occurred while preparing an index
+// declared by a document. Unlike document errors, it fails the ingest.
+type indexError struct {
+	err error
+}
+
+func (e *indexError) Error() string {
+	return e.err.Error()
+}
+
+// prepareDocumentIndex prepares an index declared by a document the first time
+// it is encountered. Existing indices are never cleared.
+func (i *Ingestor) prepareDocumentIndex(index string) error {
+	i.indexMutex.Lock()
+	idx, ok := i.indices[index]
+	if !ok {
+		idx = &documentIndex{}
+		i.indices[index] = idx
+	}
+	i.indexMutex.Unlock()
+	// the index is prepared outside of the mutex, so the cluster requests
+	// don't block workers ingesting into other indices
+	idx.once.Do(func() {
+		if !i.dryRun {
+			idx.err = i.prepareIndex(index, false)
+		}
+	})
+	if idx.err != nil {
+		return &indexError{
+			err: fmt.Errorf("Error occurred while preparing index `%s`: %v", index, idx.err),
+		}
+	}
+	return nil
+}
+
+// touchedIndices returns the target index and all successfully prepared
+// indices declared by documents, in sorted order.
+func (i *Ingestor) touchedIndices() []string {
+	i.indexMutex.Lock()
+	defer i.indexMutex.Unlock()
+	indices := make([]string, 0, len(i.indices))
+	for index, idx := range i.indices {
+		if idx.err == nil {
+			indices = append(indices, index)
+		}
+	}
+	sort.Strings(indices)
+	return indices
+}
+
+func (i *Ingestor) prepareCheckpoint() (bool, error) {
+	i.checkpointTracker = nil
+	i.target = i.newTargetIndex()
+	if i.checkpointStore == nil || i.dryRun {
+		return i.clearExisting, nil
+	}
+	cp := checkpoint.New()
+	if i.resume {
+		// load the previous checkpoint
+		loaded, err := i.checkpointStore.Load()
+		if err != nil {
+			return false, fmt.Errorf("Error occurred while loading checkpoint: %v", err)
+		}
+		cp = loaded
+	}
+	if i.alias != "" && cp.Index != "" && !cp.IsEmpty() {
+		// resume into the same timestamped index
+		i.target = cp.Index
+	}
+	cp.Index = i.target
+	i.checkpointTracker = checkpoint.NewTracker(i.checkpointStore, cp)
+	if !cp.IsEmpty() {
+		// never clear an index that is being resumed
+		log.Infof("Resuming ingest from checkpoint for %d sources", len(cp.Sources))
+		return false, nil
+	}
+	return i.clearExisting, nil
+}
+
+func (i *Ingestor) newTargetIndex() string {
+	if i.alias == "" {
+		return i.index
+	}
+	// ingest into a fresh timestamped index
+	return fmt.Sprintf("%s-%s", i.index, time.Now().UTC().Format(aliasTimestampFormat))
+}
+
+func (i *Ingestor) isAliasIndex(index string) bool {
+	prefix := i.index + "-"
+	if !strings.HasPrefix(index, prefix) {
+		return false
+	}
+	_, err := time.Parse(aliasTimestampFormat, strings.TrimPrefix(index, prefix))
+	return err == nil
+}
+
+func (i *Ingestor) swapAlias() error {
+	// get the indices currently behind the alias
+	previous, err := i.client.GetAliasIndices(i.alias)
+	if err != nil {
+		return err
+	}
+	var remove []string
+	for _, index := range previous {
+		if index != i.target {
+			remove = append(remove, index)
+		}
+	}
+	// atomically point the alias to the new index
+	log.Infof("Pointing alias `%s` to index `%s`", i.alias, i.target)
+	err = i.client.UpdateAlias(i.alias, i.target, remove)
+	if err != nil {
+		return err
+	}
+	// a negative retention keeps all previous indices
+	if i.retainIndices < 0 {
+		return nil
+	}
+	// find all previous timestamped indices, ignoring any newer than ours
+	indices, err := i.client.ListIndices(i.index + "-")
+	if err != nil {
+		return err
+	}
+	var older []string
+	for _, index := range indices {
+		if i.isAliasIndex(index) && index < i.target {
+			older = append(older, index)
+		}
+	}
+	if len(older) <= i.retainIndices {
+		return nil
+	}
+	// delete all but the most recent N
+	sort.Sort(sort.Reverse(sort.StringSlice(older)))
+	for _, index := range older[i.retainIndices:] {
+		log.Infof("Deleting previous index `%s`", index)
+		err := i.client.DeleteIndex(index)
+		if err != nil {
+			return fmt.Errorf("Error occurred while deleting previous index: %v", err)
+		}
+	}
 	return nil
 }
 
-func (i *Ingestor) enableReplicas() error {
-	log.Infof("Enabling replicas for index `%s`", i.index)
-	err := i.client.EnableReplicas(i.index, i.numReplicas)
+func (i *Ingestor) enableReplicas(index string) error {
+	log.Infof("Enabling replicas for index `%s`", index)
+	err := i.client.EnableReplicas(index, i.numReplicas)
 	if err != nil {
 		return fmt.Errorf("Error occurred while enabling replicas: %v", err)
 	}
 	return nil
 }
 
+func (i *Ingestor) refreshIndex(index string) error {
+	log.Infof("Refreshing index `%s`", index)
+	err := i.client.Refresh(index)
+	if err != nil {
+		return fmt.Errorf("Error occurred while refreshing index: %v", err)
+	}
+	return nil
+}
+
+func (i *Ingestor) forceMergeIndex(index string) error {
+	log.Infof("Force merging index `%s` to %d segments", index, i.forceMerge)
+	err := i.client.ForceMerge(index, i.forceMerge)
+	if err != nil {
+		return fmt.Errorf("Error occurred while force merging index: %v", err)
+	}
+	return nil
+}
+
+func (i *Ingestor) waitForGreenIndex(index string) error {
+	log.Infof("Waiting up to %v for index `%s` to be green", i.waitForGreen, index)
+	err := i.client.WaitForGreen(index, i.waitForGreen)
+	if err != nil {
+		return fmt.Errorf("Error occurred while waiting for index health: %v", err)
+	}
+	return nil
+}
+
+func (i *Ingestor) verifyIndices(indices []string) error {
+	for _, index := range indices {
+		// ensure every acknowledged document is visible to the count
+		err := i.client.Refresh(index)
+		if err != nil {
+			return fmt.Errorf("Error occurred while refreshing index: %v", err)
+		}
+		count, err := i.client.Count(index)
+		if err != nil {
+			return err
+		}
+		summary := i.verifier.summary(index, count)
+		i.verifySummaries = append(i.verifySummaries, summary)
+		log.Info(summary)
+		if summary.MissingRate() > i.verifyTolerance {
+			return fmt.Errorf("Verification failed for index `%s`, %d of %d documents are missing (%.4f missing rate exceeds tolerance of %.4f)",
+				index,
+				summary.NumMissing,
+				summary.NumUnique,
+				summary.MissingRate(),
+				i.verifyTolerance)
+		}
+	}
+	return nil
+}
+
+func (i *Ingestor) snapshot(indices []string) error {
+	// register the repository (if necessary)
+	exists, err := i.client.SnapshotRepositoryExists(i.snapshotRepository)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if i.snapshotLocation == "" {
+			return fmt.Errorf("Snapshot repository `%s` does not exist and no location has been provided", i.snapshotRepository)
+		}
+		log.Infof("Registering snapshot repository `%s` at `%s`", i.snapshotRepository, i.snapshotLocation)
+		err := i.client.CreateSnapshotRepository(i.snapshotRepository, i.snapshotLocation)
+		if err != nil {
+			return fmt.Errorf("Error occurred while registering snapshot repository: %v", err)
+		}
+	}
+	// name the snapshot after the target index and the time by default, as
+	// snapshot names must be unique within the repository
+	name := i.snapshotName
+	if name == "" {
+		name = fmt.Sprintf("%s-%s", i.target, time.Now().UTC().Format(snapshotTimestampFormat))
+	}
+	log.Infof("Taking snapshot `%s` in repository `%s`", name, i.snapshotRepository)
+	status, err := i.client.CreateSnapshot(i.snapshotRepository, name, indices)
+	if err != nil {
+		return fmt.Errorf("Error occurred while taking snapshot: %v", err)
+	}
+	i.snapshotStatus = status
+	log.Info(status)
+	if !status.Succeeded() {
+		return fmt.Errorf("Snapshot `%s` completed with state %s", name, status.State)
+	}
+	return nil
+}
+
 func (i *Ingestor) getBulkByteSize() int64 {
 	i.mutex.RLock()
 	bytes := i.bulkByteSize
@@ -158,6 +582,13 @@ func (i *Ingestor) setBulkByteSize(bulkByteSize int64) {
 
 // Ingest will run the ingest job.
 func (i *Ingestor) Ingest() error {
+	return i.IngestContext(context.Background())
+}
+
+// IngestContext will run the ingest job until it completes or the provided
+// context is cancelled. Upon cancellation no further input is read, all
+// in-flight bulk requests are aborted, and a *CancelledError is returned.
+func (i *Ingestor) IngestContext(ctx context.Context) error {
 
 	// check that we have the required options set
 	if i.index == "" {
@@ -176,20 +607,74 @@ func (i *Ingestor) Ingest() error {
 	// print input summary
 	log.Info(i.input.Summary())
 
-	// prepare elasticsearch index
-	err := i.prepareIndex()
+	// flush any rejected documents once the ingest ends
+	if i.deadLetter != nil {
+		defer i.flushDeadLetter()
+	}
+
+	// reset the results of any previous ingest
+	i.snapshotStatus = nil
+	i.verifySummaries = nil
+	i.verifier = nil
+	if i.verify && !i.dryRun {
+		i.verifier = newVerifier()
+	}
+
+	// load the checkpoint to resume from (if specified)
+	clearExisting, err := i.prepareCheckpoint()
 	if err != nil {
 		return err
 	}
+	// the target index is prepared below
+	target := &documentIndex{}
+	target.once.Do(func() {})
+	i.indices = map[string]*documentIndex{
+		i.target: target,
+	}
+
+	// prepare elasticsearch index, unless this is a dry run
+	if i.dryRun {
+		log.Infof("Dry run, no changes will be made to index `%s`", i.target)
+		i.dryRunSummary = newDryRunSummary()
+	} else {
+		err = i.prepareTemplates()
+		if err != nil {
+			return err
+		}
+		err = i.prepareIndex(i.target, clearExisting)
+		if err != nil {
+			return err
+		}
+	}
+
+	// restore the tuned settings however the ingest ends, so that a failed
+	// or cancelled ingest does not leave the indices tuned for bulk loading
+	restored := false
+	if i.tuneSettings && !i.dryRun {
+		defer func() {
+			if !restored {
+				i.restoreTouchedSettings()
+			}
+		}()
+	}
+
+	// reset the document error tracker
+	i.errTracker = threshold.NewTracker(i.threshold)
+
+	// report the document errors of a dry run however it ends
+	if i.dryRun {
+		defer i.finishDryRun()
+	}
 
 	// open the backpressure equalizer
-	equalizer.Open(i.numActiveConnections)
+	i.equalizer = equalizer.Open(i.numActiveConnections)
 
 	// create pool of size N
 	p := pool.New(i.numWorkers)
 
 	// start progress tracking
-	progress.StartProgress()
+	i.progress = progress.New()
+	i.progress.Start()
 
 	// start optimising if signalled.
 	if i.bulkSizeOptimiser != nil {
@@ -198,11 +683,15 @@ func (i *Ingestor) Ingest() error {
 	}
 
 	// launch the ingest job
-	err = p.Execute(i.newlineWorker(), i.input)
+	err = p.ExecuteContext(ctx, i.newlineWorker(ctx), i.input)
+	if ctx.Err() != nil {
+		// cancelled, drain any in-flight requests before returning
+		return i.cancel(ctx)
+	}
 	if err != nil {
 		// error threshold was surpassed or there was a fatal error
-		progress.EndProgress()
-		progress.PrintFailure()
+		i.progress.End()
+		i.progress.PrintFailure()
 		return err
 	}
 
@@ -210,47 +699,167 @@ func (i *Ingestor) Ingest() error {
 	i.callbackWG.Wait()
 
 	// close the backpressure equalizer
-	errs := equalizer.Close()
+	errs := i.equalizer.Close()
+	if ctx.Err() != nil {
+		// cancelled after all input was read
+		i.progress.End()
+		i.progress.PrintFailure()
+		return &CancelledError{
+			Err:          ctx.Err(),
+			NumCommitted: i.progress.GetDocCount(),
+		}
+	}
 	if len(errs) > 0 {
 		// return the first error
-		progress.EndProgress()
-		progress.PrintFailure()
+		i.progress.End()
+		i.progress.PrintFailure()
 		return errs[0]
 	}
 
+	// ensure all checkpoints were persisted
+	if i.checkpointTracker != nil {
+		if err := i.checkpointTracker.Err(); err != nil {
+			i.progress.End()
+			i.progress.PrintFailure()
+			return fmt.Errorf("Error occurred while saving checkpoint: %v", err)
+		}
+	}
+
 	// success
-	progress.EndProgress()
-	progress.PrintSuccess()
+	i.progress.End()
+	i.progress.PrintSuccess()
 
-	// enable replication
-	if i.numReplicas > 0 {
-		err := i.enableReplicas()
-		if err != nil {
+	// nothing has been written during a dry run, so finish
+	if i.dryRun {
+		return nil
+	}
+
+	indices := i.touchedIndices()
+
+	// restore the tuned settings (if necessary)
+	if i.tuneSettings {
+		restored = true
+		for _, index := range indices {
+			if err := i.restoreSettings(index); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, index := range indices {
+		// refresh the index (if necessary)
+		if i.refresh {
+			if err := i.refreshIndex(index); err != nil {
+				return err
+			}
+		}
+
+		// force merge the index before it is replicated (if necessary)
+		if i.forceMerge > 0 {
+			if err := i.forceMergeIndex(index); err != nil {
+				return err
+			}
+		}
+
+		// enable replication
+		if i.numReplicas > 0 {
+			err := i.enableReplicas(index)
+			if err != nil {
+				return err
+			}
+		}
+
+		// set the index as read-only (if necessary)
+		if err := i.client.SetReadOnly(index, i.readOnly); err != nil {
+			return err
+		}
+
+		// set the index as block write (if necessary)
+		if err := i.client.SetBlockWrite(index, i.blockWrite); err != nil {
 			return err
 		}
 	}
 
-	// set the index as read-only (if necessary)
-	if err := i.client.SetReadOnly(i.index, i.readOnly); err != nil {
-		return err
+	// wait for the replicas of every index to be allocated (if necessary)
+	if i.waitForGreen > 0 {
+		for _, index := range indices {
+			if err := i.waitForGreenIndex(index); err != nil {
+				return err
+			}
+		}
 	}
 
-	// set the index as block write (if necessary)
-	if err := i.client.SetBlockWrite(i.index, i.blockWrite); err != nil {
-		return err
+	// verify the document count of every index (if necessary)
+	if i.verifier != nil {
+		if err := i.verifyIndices(indices); err != nil {
+			return err
+		}
+	}
+
+	// snapshot the ingested indices (if necessary)
+	if i.snapshotRepository != "" {
+		if err := i.snapshot(indices); err != nil {
+			return err
+		}
+	}
+
+	// swap the alias over to the new index (if necessary)
+	if i.alias != "" {
+		if err := i.swapAlias(); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// DocErrs returns all document ingest errors.
-func DocErrs() []error {
-	return threshold.Errs()
+// finishDryRun records the document errors in the dry run summary and logs it.
+func (i *Ingestor) finishDryRun() {
+	i.dryRunSummary.NumErrors = int64(len(i.errTracker.Errs()))
+	log.Info(i.dryRunSummary)
+}
+
+// DryRunSummary returns the summary of the most recent dry run ingest, or nil
+// if no dry run has been performed.
+func (i *Ingestor) DryRunSummary() *DryRunSummary {
+	return i.dryRunSummary
+}
+
+// VerifySummaries returns the verification summary of every index of the most
+// recent ingest, or nil if no verification was performed.
+func (i *Ingestor) VerifySummaries() []*VerifySummary {
+	return i.verifySummaries
+}
+
+// SnapshotStatus returns the status of the snapshot taken by the most recent
+// ingest, or nil if no snapshot was taken.
+func (i *Ingestor) SnapshotStatus() *SnapshotStatus {
+	return i.snapshotStatus
+}
+
+func (i *Ingestor) cancel(ctx context.Context) error {
+	// wait until all callbacks executed
+	i.callbackWG.Wait()
+	// close the backpressure equalizer, errors are expected since the
+	// outstanding requests were aborted
+	i.equalizer.Close()
+	i.progress.End()
+	i.progress.PrintFailure()
+	return &CancelledError{
+		Err:          ctx.Err(),
+		NumCommitted: i.progress.GetDocCount(),
+	}
+}
+
+// DocErrs returns all document ingest errors from the most recent ingest.
+func (i *Ingestor) DocErrs() []error {
+	return i.errTracker.Errs()
 }
 
-// SampleDocErrs returns an N sized sample of document ingest errors.
-func SampleDocErrs(n int) []error {
-	return threshold.SampleErrs(n)
+// SampleDocErrs returns an N sized sample of document ingest errors from the
+// most recent ingest.
+func (i *Ingestor) SampleDocErrs(n int) []error {
+	return i.errTracker.SampleErrs(n)
 }
 
 func getReader(reader io.Reader, compression string) (io.Reader, error) {
@@ -269,103 +878,326 @@ func getReader(reader io.Reader, compression string) (io.Reader, error) {
 	}
 }
 
-func (i *Ingestor) createProgressCallback(bytes, docs int64) equalizer.CallbackFunc {
+func (i *Ingestor) flushDeadLetter() {
+	err := i.deadLetter.Flush()
+	if err != nil {
+		log.Errorf("Error occurred while flushing dead letter sink: %v", err)
+	}
+}
+
+func (i *Ingestor) writeDeadLetter(record *deadletter.Record) error {
+	err := i.deadLetter.Write(record)
+	if err != nil {
+		return fmt.Errorf("Error occurred while writing to dead letter sink: %v", err)
+	}
+	return nil
+}
+
+func (i *Ingestor) createProgressCallback(bulk BulkRequest, source string, records []*deadletter.Record, items []*BulkItem, bytes, docs int64, ack func(error)) equalizer.CallbackFunc {
 	// increment callback waitgroup
 	i.callbackWG.Add(1)
-	return func(err error) {
+	return func(err error) error {
+		// decrement waitgroup
+		defer i.callbackWG.Done()
 		if err == nil {
-			// update and print current progress if no error
-			progress.UpdateProgress(bytes, docs)
+			// record each rejected document as an individual error
+			failed := bulk.Failed()
+			for _, item := range failed {
+				item.Source = source
+				if i.errTracker.CheckRejected(item) {
+					err = i.errTracker.Err()
+				}
+				// write the rejected document to the dead letter sink
+				if item.Item < len(records) {
+					record := *records[item.Item]
+					record.Error = item.Error()
+					if werr := i.writeDeadLetter(&record); werr != nil {
+						err = werr
+					}
+				}
+			}
+			// record the accepted documents for verification
+			if i.verifier != nil {
+				i.verifier.addAccepted(i.target, items, failed)
+			}
+			// update and print current progress
+			i.progress.Update(bytes, docs-int64(len(failed)))
 		}
-		// decrement waitgroup
-		i.callbackWG.Done()
+		if ack != nil {
+			// acknowledge the checkpoint
+			ack(err)
+		}
+		return err
 	}
 }
 
-func (i *Ingestor) addLineToBulkRequest(bulk BulkRequest, line string) (bool, error) {
+func (i *Ingestor) addLineToBulkRequest(bulk BulkRequest, line string) (*BulkItem, error) {
 	// instantiate a new document
 	document, err := i.documentCtor()
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	// set data for document
 	err = document.SetData(line)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	// get id from document
 	id, err := document.GetID()
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	// gracefully handle nil id
 	if id == "" {
-		return false, nil
+		return nil, nil
 	}
 	// get type from document
 	typ, err := document.GetType()
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	// gracefully handle nil type
 	if typ == "" {
-		return false, nil
+		return nil, nil
 	}
-	// get source from document
-	source, err := document.GetSource()
-	if err != nil {
-		return false, err
+	// get operation from document, defaulting to index
+	op := OperationIndex
+	if doc, ok := document.(DocumentOperation); ok {
+		op, err = doc.GetOperation()
+		if err != nil {
+			return nil, err
+		}
 	}
-	// gracefully handle nil source
-	if source == nil {
-		return false, nil
+	switch op {
+	case "":
+		op = OperationIndex
+	case OperationIndex, OperationCreate, OperationUpdate, OperationUpsert, OperationScript, OperationDelete:
+	default:
+		return nil, fmt.Errorf("Unsupported operation `%s` for document `%s`", op, id)
+	}
+	var source interface{}
+	if op != OperationDelete {
+		// get source from document
+		source, err = document.GetSource()
+		if err != nil {
+			return nil, err
+		}
+		// gracefully handle nil source
+		if source == nil {
+			return nil, nil
+		}
+		if _, ok := source.(*Script); op == OperationScript && !ok {
+			return nil, fmt.Errorf("Source for scripted update of document `%s` must be a *Script", id)
+		}
+	}
+	// get optional metadata from document
+	var metadata *Metadata
+	if doc, ok := document.(DocumentMetadata); ok {
+		metadata, err = doc.GetMetadata()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// get optional target index from document
+	var index string
+	if doc, ok := document.(DocumentIndex); ok {
+		index, err = doc.GetIndex()
+		if err != nil {
+			return nil, err
+		}
+		// create the index on demand
+		if index != "" {
+			err = i.prepareDocumentIndex(index)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 	// add document to bulk req
-	bulk.Add(typ, id, source)
-	// flag that the line was parsed successfully
-	return true, nil
+	item := &BulkItem{
+		Operation: op,
+		Index:     index,
+		Type:      typ,
+		ID:        id,
+		Source:    source,
+		Metadata:  metadata,
+	}
+	bulk.AddItem(item)
+	// return the item to flag that the line was parsed successfully
+	return item, nil
+}
+
+func sourceName(next io.Reader) string {
+	named, ok := next.(input.NamedReader)
+	if !ok {
+		return ""
+	}
+	return named.Name()
+}
+
+func (i *Ingestor) openCursor(name string) (*checkpoint.Cursor, checkpoint.Source) {
+	// only named sources can be checkpointed
+	if i.checkpointTracker == nil || name == "" {
+		return nil, checkpoint.Source{}
+	}
+	return i.checkpointTracker.Open(name), i.checkpointTracker.Get(name)
+}
+
+func seekToOffset(reader io.Reader, offset checkpoint.Source, compression string) bool {
+	// byte offsets are into the decompressed stream, so only seek if there
+	// is no compression
+	if offset.Bytes == 0 || compression != "" {
+		return false
+	}
+	seeker, ok := reader.(io.Seeker)
+	if !ok {
+		return false
+	}
+	_, err := seeker.Seek(offset.Bytes, io.SeekStart)
+	return err == nil
+}
+
+func countBytes(split bufio.SplitFunc, count *int64) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := split(data, atEOF)
+		*count += int64(advance)
+		return advance, token, err
+	}
 }
 
-func (i *Ingestor) newlineWorker() pool.Worker {
+func (i *Ingestor) newlineWorker(ctx context.Context) pool.Worker {
 	return func(next io.Reader) error {
 
+		// close the source once it has been read
+		if closer, ok := next.(io.Closer); ok {
+			defer closer.Close()
+		}
+
+		// get the name of the source (if available)
+		name := sourceName(next)
+
+		// get the checkpoint cursor and resume offset for the source
+		cursor, offset := i.openCursor(name)
+		if offset.Complete {
+			// source was fully ingested by a previous run
+			return nil
+		}
+
+		// detect the compression of the source (if specified)
+		source := next
+		compression := i.compression
+		if compression == CompressionAuto {
+			compression = ""
+			// only the first segment of a split file begins with magic bytes
+			if segment, ok := next.(*input.Segment); !ok || segment.Start() == 0 {
+				var err error
+				source, compression, err = detectCompression(next, name)
+				if err != nil {
+					return fmt.Errorf("Error occurred while detecting compression of `%s`: %v", name, err)
+				}
+			}
+		}
+
+		// seek past previously acknowledged bytes (if possible)
+		skipLines := offset.Lines
+		lineOffset := int64(0)
+		byteOffset := int64(0)
+		if seekToOffset(source, offset, compression) {
+			skipLines = 0
+			lineOffset = offset.Lines
+			byteOffset = offset.Bytes
+		}
+
+		// segments of split files begin mid-stream, so cannot be decompressed
+		if _, ok := next.(*input.Segment); ok && compression != "" {
+			return fmt.Errorf("Segment `%s` of a split file cannot be decompressed with `%s` compression", name, compression)
+		}
+
 		// get decompress reader (if compression is specified / supported)
-		reader, err := getReader(next, i.compression)
-		if threshold.CheckErr(err, i.threshold) {
-			return threshold.NewErr(i.threshold)
+		reader, err := getReader(source, compression)
+		if i.errTracker.CheckErr(err) {
+			return i.errTracker.Err()
 		}
 
 		// scan file line by line
 		scanner := bufio.NewScanner(reader)
 		// allocate a large enough buffer
 		scanner.Buffer(make([]byte, i.scanBufferSize), i.scanBufferSize)
+		// track the number of bytes consumed by the scanner
+		scanner.Split(countBytes(bufio.ScanLines, &byteOffset))
+
+		// skip past previously acknowledged lines
+		for lineOffset < skipLines && scanner.Scan() {
+			lineOffset++
+		}
 
 		for {
+			// stop reading if the ingest has been cancelled
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
 			// total bytes sent
 			bytes := int64(0)
 			docs := int64(0)
 
+			// the lines in the bulk request, only kept for the dead letter sink
+			var records []*deadletter.Record
+			// the items in the bulk request, only kept for verification
+			var items []*BulkItem
+
 			// create a new bulk request object
-			bulk := i.client.NewBulkRequest(i.index)
+			bulk := i.client.NewBulkRequest(i.target)
 
 			// begin reading file, line by line
 			for scanner.Scan() {
 
 				// read line of file
 				line := scanner.Text()
+				lineOffset++
 
 				// add line to bulk index request
-				success, err := i.addLineToBulkRequest(bulk, line)
-				if threshold.CheckErr(err, i.threshold) {
-					return threshold.NewErr(i.threshold)
+				item, err := i.addLineToBulkRequest(bulk, line)
+				if ierr, ok := err.(*indexError); ok {
+					// the document is not at fault, so always fail the ingest
+					return ierr.err
+				}
+				if err != nil && i.deadLetter != nil {
+					// write the unparsable document to the dead letter sink
+					werr := i.writeDeadLetter(&deadletter.Record{
+						Line:       line,
+						Source:     name,
+						LineNumber: lineOffset,
+						Error:      err.Error(),
+					})
+					if werr != nil {
+						return werr
+					}
+				}
+				if i.errTracker.CheckErr(err) {
+					return i.errTracker.Err()
 				}
 
 				// ensure that the request was created
-				if success {
+				if item != nil {
 					docs = docs + 1
 
+					// keep the item to verify the document once accepted
+					if i.verifier != nil {
+						items = append(items, item)
+					}
+
+					// keep the line in case the document is rejected
+					if i.deadLetter != nil {
+						records = append(records, &deadletter.Record{
+							Line:       line,
+							Source:     name,
+							LineNumber: lineOffset,
+						})
+					}
+
 					// flag this document as successful
-					threshold.AddSuccess()
+					i.errTracker.AddSuccess()
 					// check if we have hit batch size limit
 					if bulk.EstimatedSizeInBytes() >= i.getBulkByteSize() {
 						// ready to send
@@ -376,12 +1208,15 @@ func (i *Ingestor) newlineWorker() pool.Worker {
 
 			// check if scanner encountered an err
 			err := scanner.Err()
-			if threshold.CheckErr(err, i.threshold) {
-				return threshold.NewErr(i.threshold)
+			if i.errTracker.CheckErr(err) {
+				return i.errTracker.Err()
 			}
 
 			// if no actions, we are finished
 			if bulk.Size() == 0 {
+				if cursor != nil {
+					cursor.Done(lineOffset, byteOffset)
+				}
 				break
 			}
 
@@ -391,14 +1226,24 @@ func (i *Ingestor) newlineWorker() pool.Worker {
 			// create the callback to be executed after this bulk request
 			// succeeds. This is required ensure that the correct `bytes`
 			// value is snapshotted.
-			callback := i.createProgressCallback(bytes, docs)
+			var ack func(error)
+			if cursor != nil {
+				ack = cursor.Add(lineOffset, byteOffset)
+			}
+			callback := i.createProgressCallback(bulk, name, records, items, bytes, docs, ack)
 
 			// send the request through the equalizer, this will wait until the
 			// equalizer determines ES is 'ready'.
 			// NOTE: Due to the asynchronous nature of the equalizer, error
 			// values returned here may not be caused from this worker
 			// goroutine.
-			err = equalizer.Send(bulk, callback)
+			if i.dryRun {
+				// acknowledge the request without sending it
+				i.dryRunSummary.addBulk(bytes, docs)
+				err = callback(nil)
+			} else {
+				err = i.equalizer.Send(ctx, bulk, callback)
+			}
 			if err != nil {
 				// always return on bulk ingest error
 				return err
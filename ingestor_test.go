@@ -0,0 +1,1350 @@
+package deluge_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/unchartedsoftware/deluge"
+	"github.com/unchartedsoftware/deluge/backoff"
+	"github.com/unchartedsoftware/deluge/checkpoint"
+	"github.com/unchartedsoftware/deluge/deadletter"
+	"github.com/unchartedsoftware/deluge/elastic/estest"
+	"github.com/unchartedsoftware/deluge/elastic/v7"
+	"github.com/unchartedsoftware/deluge/input"
+	"github.com/unchartedsoftware/deluge/util"
+)
+
+const (
+	testIndex   = "test"
+	testMapping = `{"properties":{"name":{"type":"keyword"}}}`
+)
+
+// testDocument parses lines of the form {"id":"0","name":"doc-0"}.
+type testDocument struct {
+	data map[string]interface{}
+}
+
+func newTestDocument() (deluge.Document, error) {
+	return &testDocument{}, nil
+}
+
+func (d *testDocument) SetData(data interface{}) error {
+	return json.Unmarshal([]byte(data.(string)), &d.data)
+}
+
+func (d *testDocument) GetSource() (interface{}, error) {
+	return d.data, nil
+}
+
+func (d *testDocument) GetID() (string, error) {
+	id, _ := d.data["id"].(string)
+	return id, nil
+}
+
+func (d *testDocument) GetMapping() (string, error) {
+	return testMapping, nil
+}
+
+func (d *testDocument) GetType() (string, error) {
+	return "datum", nil
+}
+
+// hitDocument parses the hits read from an elasticsearch input.
+type hitDocument struct {
+	testDocument
+	id string
+}
+
+func newHitDocument() (deluge.Document, error) {
+	return &hitDocument{}, nil
+}
+
+func (d *hitDocument) SetData(data interface{}) error {
+	hit := struct {
+		ID     string                 `json:"_id"`
+		Source map[string]interface{} `json:"_source"`
+	}{}
+	err := json.Unmarshal([]byte(data.(string)), &hit)
+	if err != nil {
+		return err
+	}
+	d.id = hit.ID
+	d.data = hit.Source
+	return nil
+}
+
+func (d *hitDocument) GetID() (string, error) {
+	return d.id, nil
+}
+
+// opDocument parses lines of the form {"id":"0","op":"update","name":"doc-0"},
+// applying the operation given by the op field.
+type opDocument struct {
+	testDocument
+}
+
+func newOpDocument() (deluge.Document, error) {
+	return &opDocument{}, nil
+}
+
+func (d *opDocument) GetOperation() (deluge.Operation, error) {
+	op, _ := d.data["op"].(string)
+	return deluge.Operation(op), nil
+}
+
+func (d *opDocument) GetSource() (interface{}, error) {
+	if d.data["op"] == string(deluge.OperationScript) {
+		return &deluge.Script{
+			Source: "ctx._source.name = params.name",
+			Params: map[string]interface{}{"name": d.data["name"]},
+		}, nil
+	}
+	source := make(map[string]interface{})
+	for key, value := range d.data {
+		if key != "op" {
+			source[key] = value
+		}
+	}
+	return source, nil
+}
+
+// routedDocument routes documents by name and versions them externally.
+type routedDocument struct {
+	testDocument
+}
+
+func newRoutedDocument() (deluge.Document, error) {
+	return &routedDocument{}, nil
+}
+
+func (d *routedDocument) GetMetadata() (*deluge.Metadata, error) {
+	name, _ := d.data["name"].(string)
+	version := int64(10)
+	return &deluge.Metadata{
+		Routing:     name,
+		Version:     &version,
+		VersionType: "external_gte",
+	}, nil
+}
+
+// monthlyDocument ingests documents into one of three monthly indices.
+type monthlyDocument struct {
+	testDocument
+}
+
+func newMonthlyDocument() (deluge.Document, error) {
+	return &monthlyDocument{}, nil
+}
+
+func (d *monthlyDocument) GetIndex() (string, error) {
+	var n int
+	fmt.Sscanf(d.data["id"].(string), "%d", &n)
+	return fmt.Sprintf("%s-2020.%02d", testIndex, n%3+1), nil
+}
+
+// testInput returns each source as a separate named reader.
+type testInput struct {
+	sources    []string
+	next       int
+	unseekable bool
+}
+
+func (i *testInput) Next() (io.Reader, error) {
+	if i.next >= len(i.sources) {
+		return nil, io.EOF
+	}
+	i.next++
+	name := fmt.Sprintf("source-%d", i.next)
+	var reader io.Reader = strings.NewReader(i.sources[i.next-1])
+	if i.unseekable {
+		// hide the Seek method, like the body of a network response
+		reader = ioutil.NopCloser(reader)
+	}
+	return input.NewReader(reader, name), nil
+}
+
+func (i *testInput) Summary() string {
+	return fmt.Sprintf("Input contains %d sources", len(i.sources))
+}
+
+// newTestInput returns an input of numDocs documents spread across numSources
+// sources. Documents whose index is flagged as invalid are malformed.
+func newTestInput(numSources int, numDocs int, invalid func(int) bool) *testInput {
+	sources := make([]string, numSources)
+	for n := 0; n < numDocs; n++ {
+		line := fmt.Sprintf("{\"id\":\"%d\",\"name\":\"doc-%d\"}\n", n, n)
+		if invalid != nil && invalid(n) {
+			line = fmt.Sprintf("{\"id\":\"%d\",\n", n)
+		}
+		sources[n%numSources] += line
+	}
+	return &testInput{
+		sources: sources,
+	}
+}
+
+func newTestClient(t *testing.T, options ...estest.OptionFunc) (*estest.Server, *elastic.Client) {
+	server := estest.NewServer(options...)
+	client, err := elastic.NewClient(1, elastic.SetURL(server.URL))
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	b, err := backoff.New(
+		backoff.SetInitialDelay(time.Millisecond),
+		backoff.SetMaxDelay(time.Millisecond))
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	client.SetBulkBackoff(b)
+	return server, client
+}
+
+func newTestIngestor(t *testing.T, client deluge.Client, input deluge.Input, options ...deluge.IngestorOptionFunc) *deluge.Ingestor {
+	ingestor, err := deluge.NewIngestor(append([]deluge.IngestorOptionFunc{
+		deluge.SetDocument(newTestDocument),
+		deluge.SetClient(client),
+		deluge.SetInput(input),
+		deluge.SetIndex(testIndex),
+		deluge.SetNumWorkers(4),
+		deluge.SetActiveConnections(4),
+		deluge.SetBulkByteSize(4 * 1024),
+	}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ingestor
+}
+
+func failEvery(nth int) estest.ItemFailer {
+	return func(index string, id string, source json.RawMessage) *estest.ItemError {
+		var n int
+		fmt.Sscanf(id, "%d", &n)
+		if n%nth != 0 {
+			return nil
+		}
+		return &estest.ItemError{
+			Status: http.StatusBadRequest,
+			Type:   "mapper_parsing_exception",
+			Reason: "failed to parse field [name]",
+		}
+	}
+}
+
+func TestIngest(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(4, 1000, nil),
+		deluge.SetNumReplicas(2))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if server.NumDocs(testIndex) != 1000 {
+		t.Errorf("expected 1000 documents, got %d", server.NumDocs(testIndex))
+	}
+	source, ok := server.Document(testIndex, "42")
+	if !ok || string(source) != `{"id":"42","name":"doc-42"}` {
+		t.Errorf("unexpected document source %s", source)
+	}
+	if server.Mapping(testIndex) != testMapping {
+		t.Errorf("expected mapping %s, got %s", testMapping, server.Mapping(testIndex))
+	}
+	if server.Setting(testIndex, "number_of_replicas") != "2" {
+		t.Errorf("expected 2 replicas, got %s", server.Setting(testIndex, "number_of_replicas"))
+	}
+	if server.NumRequests("POST", "/_bulk") < 2 {
+		t.Errorf("expected the documents to be split across bulk requests, got %d",
+			server.NumRequests("POST", "/_bulk"))
+	}
+	if len(ingestor.DocErrs()) != 0 {
+		t.Errorf("expected no document errors, got %v", ingestor.DocErrs())
+	}
+}
+
+func TestIngestClearExisting(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	if err := server.PutDocument(testIndex, "existing", map[string]string{"name": "existing"}); err != nil {
+		t.Fatal(err)
+	}
+	ingestor := newTestIngestor(t, client, newTestInput(1, 10, nil),
+		deluge.ClearExistingIndex(true))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.Document(testIndex, "existing"); ok {
+		t.Error("expected the existing document to be cleared")
+	}
+	if server.NumDocs(testIndex) != 10 {
+		t.Errorf("expected 10 documents, got %d", server.NumDocs(testIndex))
+	}
+}
+
+func TestIngestKeepExisting(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	if err := server.PutDocument(testIndex, "existing", map[string]string{"name": "existing"}); err != nil {
+		t.Fatal(err)
+	}
+	ingestor := newTestIngestor(t, client, newTestInput(1, 10, nil),
+		deluge.ClearExistingIndex(false),
+		deluge.SetUpdateMapping(true))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.Document(testIndex, "existing"); !ok {
+		t.Error("expected the existing document to be kept")
+	}
+	if server.NumDocs(testIndex) != 11 {
+		t.Errorf("expected 11 documents, got %d", server.NumDocs(testIndex))
+	}
+	if server.Mapping(testIndex) != testMapping {
+		t.Errorf("expected mapping %s, got %s", testMapping, server.Mapping(testIndex))
+	}
+}
+
+func TestIngestReadOnly(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(1, 10, nil),
+		deluge.SetReadOnly(true),
+		deluge.SetBlockWrite(true))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if server.Setting(testIndex, "blocks.read_only") != "true" {
+		t.Error("expected the index to be read only")
+	}
+	if server.Setting(testIndex, "blocks.write") != "true" {
+		t.Error("expected the index to block writes")
+	}
+}
+
+func TestIngestParseErrorsBelowThreshold(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(4, 1000, func(n int) bool {
+		return n%200 == 0
+	}), deluge.SetErrorThreshold(0.01))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if server.NumDocs(testIndex) != 995 {
+		t.Errorf("expected 995 documents, got %d", server.NumDocs(testIndex))
+	}
+	if len(ingestor.DocErrs()) != 5 {
+		t.Errorf("expected 5 document errors, got %d", len(ingestor.DocErrs()))
+	}
+	if len(ingestor.SampleDocErrs(2)) != 2 {
+		t.Errorf("expected 2 sampled document errors, got %d", len(ingestor.SampleDocErrs(2)))
+	}
+}
+
+func TestIngestParseErrorsAboveThreshold(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(4, 1000, func(n int) bool {
+		return n%10 != 0
+	}), deluge.SetErrorThreshold(0.01))
+	if err := ingestor.Ingest(); err == nil {
+		t.Fatal("expected the ingest to fail when exceeding the error threshold")
+	}
+}
+
+func TestIngestItemFailures(t *testing.T) {
+	server, client := newTestClient(t, estest.SetItemFailer(failEvery(100)))
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(4, 1000, nil),
+		deluge.SetErrorThreshold(0.05))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if server.NumDocs(testIndex) != 990 {
+		t.Errorf("expected 990 documents, got %d", server.NumDocs(testIndex))
+	}
+	errs := ingestor.DocErrs()
+	if len(errs) != 10 {
+		t.Fatalf("expected 10 document errors, got %d", len(errs))
+	}
+	var itemErr *deluge.BulkItemError
+	if !errors.As(errs[0], &itemErr) || itemErr.Type != "mapper_parsing_exception" {
+		t.Errorf("expected a bulk item error, got %v", errs[0])
+	}
+}
+
+func TestIngestItemFailuresAboveThreshold(t *testing.T) {
+	server, client := newTestClient(t, estest.SetItemFailer(failEvery(1)))
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(4, 1000, nil),
+		deluge.SetErrorThreshold(0.01))
+	if err := ingestor.Ingest(); err == nil {
+		t.Fatal("expected the ingest to fail when exceeding the error threshold")
+	}
+}
+
+func TestIngestRetriesRejectedItems(t *testing.T) {
+	server, client := newTestClient(t, estest.SetRejectedItems(100))
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(4, 1000, nil))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if server.NumDocs(testIndex) != 1000 {
+		t.Errorf("expected 1000 documents, got %d", server.NumDocs(testIndex))
+	}
+	if len(ingestor.DocErrs()) != 0 {
+		t.Errorf("expected no document errors, got %v", ingestor.DocErrs())
+	}
+}
+
+func TestIngestRejectedBulk(t *testing.T) {
+	server, client := newTestClient(t, estest.SetRejectedBulks(1))
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(4, 1000, nil))
+	if err := ingestor.Ingest(); err == nil {
+		t.Fatal("expected the ingest to fail when a bulk request is rejected")
+	}
+}
+
+func TestIngestCancel(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(4, 10000, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		// cancel once the first documents have been committed
+		for server.NumDocs(testIndex) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	err := ingestor.IngestContext(ctx)
+	var cancelled *deluge.CancelledError
+	if !errors.As(err, &cancelled) {
+		t.Fatalf("expected a cancelled error, got %v", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the error to wrap context.Canceled, got %v", cancelled.Err)
+	}
+	if cancelled.NumCommitted > int64(server.NumDocs(testIndex)) {
+		t.Errorf("expected at most %d committed documents, got %d",
+			server.NumDocs(testIndex),
+			cancelled.NumCommitted)
+	}
+}
+
+func TestIngestTimeout(t *testing.T) {
+	server, client := newTestClient(t, estest.SetLatency(50*time.Millisecond))
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(4, 10000, nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err := ingestor.IngestContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline exceeded error, got %v", err)
+	}
+}
+
+func TestIngestDeadLetter(t *testing.T) {
+	server, client := newTestClient(t, estest.SetItemFailer(failEvery(100)))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "deluge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rejected.ndjson")
+	sink, err := deadletter.NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ingestor := newTestIngestor(t, client, newTestInput(4, 1000, func(n int) bool {
+		return n%250 == 1
+	}), deluge.SetErrorThreshold(0.05), deluge.SetDeadLetter(sink))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	numRecords := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		record := &deadletter.Record{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Line == "" || record.Error == "" || record.Source == "" {
+			t.Errorf("incomplete dead-letter record %s", scanner.Text())
+		}
+		numRecords++
+	}
+	// 10 rejected documents and 4 malformed lines
+	if numRecords != 14 {
+		t.Errorf("expected 14 dead-letter records, got %d", numRecords)
+	}
+}
+
+func TestIngestDryRun(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(4, 1000, func(n int) bool {
+		return n%100 == 0
+	}), deluge.SetDryRun(true), deluge.SetErrorThreshold(0.05))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if server.NumRequests("POST", "/_bulk") != 0 {
+		t.Errorf("expected no bulk requests, got %d", server.NumRequests("POST", "/_bulk"))
+	}
+	if server.IndexExists(testIndex) {
+		t.Error("expected the index to not be created")
+	}
+	summary := ingestor.DryRunSummary()
+	if summary.NumDocs != 990 {
+		t.Errorf("expected 990 documents, got %d", summary.NumDocs)
+	}
+	if summary.NumErrors != 10 {
+		t.Errorf("expected 10 errors, got %d", summary.NumErrors)
+	}
+}
+
+func TestIngestDryRunAboveThreshold(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(4, 1000, func(n int) bool {
+		return n%10 != 0
+	}), deluge.SetDryRun(true), deluge.SetErrorThreshold(0.05))
+	if err := ingestor.Ingest(); err == nil {
+		t.Fatal("expected the dry run to abort when exceeding the error threshold")
+	}
+	summary := ingestor.DryRunSummary()
+	if summary.NumErrors == 0 {
+		t.Errorf("expected the summary to report the errors before the abort")
+	}
+	if summary.NumErrors != int64(len(ingestor.DocErrs())) {
+		t.Errorf("expected %d errors, got %d", len(ingestor.DocErrs()), summary.NumErrors)
+	}
+}
+
+func TestIngestAlias(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	previous := []string{
+		testIndex + "-20200101000000",
+		testIndex + "-20200102000000",
+	}
+	for _, index := range previous {
+		server.CreateIndex(index)
+	}
+	if err := client.UpdateAlias(testIndex, previous[1], nil); err != nil {
+		t.Fatal(err)
+	}
+	ingestor := newTestIngestor(t, client, newTestInput(4, 100, nil),
+		deluge.SetAlias(testIndex),
+		deluge.SetRetainIndices(1))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	indices := server.AliasIndices(testIndex)
+	if len(indices) != 1 || indices[0] == previous[1] || !strings.HasPrefix(indices[0], testIndex+"-") {
+		t.Fatalf("expected the alias to point to a new index, got %v", indices)
+	}
+	if server.NumDocs(indices[0]) != 100 {
+		t.Errorf("expected 100 documents, got %d", server.NumDocs(indices[0]))
+	}
+	if server.IndexExists(previous[0]) {
+		t.Errorf("expected index `%s` to be deleted", previous[0])
+	}
+	if !server.IndexExists(previous[1]) {
+		t.Errorf("expected index `%s` to be retained", previous[1])
+	}
+}
+
+func TestIngestResumeFromCheckpoint(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "deluge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := checkpoint.NewFileStore(filepath.Join(dir, "checkpoint.json"))
+	ingestor := newTestIngestor(t, client, newTestInput(4, 1000, nil),
+		deluge.SetCheckpointStore(store))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, source := range cp.Sources {
+		if !source.Complete || source.Lines != 250 {
+			t.Errorf("expected source `%s` to be complete with 250 lines, got %+v", name, source)
+		}
+	}
+	numBulks := server.NumRequests("POST", "/_bulk")
+	// resuming a complete ingest should not send any documents
+	ingestor = newTestIngestor(t, client, newTestInput(4, 1000, nil),
+		deluge.SetCheckpointStore(store),
+		deluge.ResumeFromCheckpoint(true))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if server.NumRequests("POST", "/_bulk") != numBulks {
+		t.Errorf("expected no additional bulk requests, got %d",
+			server.NumRequests("POST", "/_bulk")-numBulks)
+	}
+	if server.NumDocs(testIndex) != 1000 {
+		t.Errorf("expected 1000 documents, got %d", server.NumDocs(testIndex))
+	}
+}
+
+func TestIngestFromElasticInput(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	for n := 0; n < 250; n++ {
+		err := server.PutDocument("source", fmt.Sprintf("%d", n), map[string]string{
+			"name": fmt.Sprintf("doc-%d", n),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	input, err := deluge.NewElasticInput(client, "source", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ingestor := newTestIngestor(t, client, input,
+		deluge.SetDocument(newHitDocument))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if server.NumDocs(testIndex) != 250 {
+		t.Errorf("expected 250 documents, got %d", server.NumDocs(testIndex))
+	}
+	source, ok := server.Document(testIndex, "7")
+	if !ok || string(source) != `{"name":"doc-7"}` {
+		t.Errorf("unexpected document source %s", source)
+	}
+}
+
+func TestIngestConcurrent(t *testing.T) {
+	failing, failingClient := newTestClient(t, estest.SetItemFailer(failEvery(100)))
+	defer failing.Close()
+	slow, slowClient := newTestClient(t, estest.SetLatency(10*time.Millisecond))
+	defer slow.Close()
+	first := newTestIngestor(t, failingClient, newTestInput(4, 1000, nil),
+		deluge.SetErrorThreshold(0.05))
+	second := newTestIngestor(t, slowClient, newTestInput(4, 10000, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var firstErr, secondErr error
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		firstErr = first.Ingest()
+		// cancel the second ingest once both have committed documents
+		for slow.NumDocs(testIndex) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	go func() {
+		defer wg.Done()
+		secondErr = second.IngestContext(ctx)
+	}()
+	wg.Wait()
+	if firstErr != nil {
+		t.Fatal(firstErr)
+	}
+	if failing.NumDocs(testIndex) != 990 {
+		t.Errorf("expected 990 documents, got %d", failing.NumDocs(testIndex))
+	}
+	if len(first.DocErrs()) != 10 {
+		t.Errorf("expected 10 document errors, got %d", len(first.DocErrs()))
+	}
+	// the errors of the first ingest do not count against the second
+	if len(second.DocErrs()) != 0 {
+		t.Errorf("expected no document errors, got %v", second.DocErrs())
+	}
+	var cancelled *deluge.CancelledError
+	if !errors.As(secondErr, &cancelled) {
+		t.Fatalf("expected a cancelled error, got %v", secondErr)
+	}
+	// the progress of the first ingest is not included in the second
+	if cancelled.NumCommitted > int64(slow.NumDocs(testIndex)) {
+		t.Errorf("expected at most %d committed documents, got %d",
+			slow.NumDocs(testIndex),
+			cancelled.NumCommitted)
+	}
+}
+
+func TestIngestFromStreamInput(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	lines := ""
+	for n := 0; n < 1000; n++ {
+		lines += fmt.Sprintf("{\"id\":\"%d\",\"name\":\"doc-%d\"}\n", n, n)
+	}
+	// stream the lines through a pipe, which cannot be seeked or sized
+	reader, writer := io.Pipe()
+	go func() {
+		writer.Write([]byte(lines))
+		writer.Close()
+	}()
+	input, err := deluge.NewStreamInput(reader, "pipe", 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the summary logged before the ingest cannot know the size of the stream
+	if summary := input.Summary(); !strings.Contains(summary, "unknown size") {
+		t.Errorf("expected summary to report an unknown size, got %s", summary)
+	}
+	ingestor := newTestIngestor(t, client, input)
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if server.NumDocs(testIndex) != 1000 {
+		t.Errorf("expected 1000 documents, got %d", server.NumDocs(testIndex))
+	}
+	if len(ingestor.DocErrs()) != 0 {
+		t.Errorf("expected no document errors, got %v", ingestor.DocErrs())
+	}
+	summary := input.Summary()
+	if !strings.Contains(summary, util.FormatBytes(int64(len(lines)))) {
+		t.Errorf("expected summary to report %d bytes consumed, got %s", len(lines), summary)
+	}
+}
+
+func writeTestFile(t *testing.T, path string, numDocs int, compress bool) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var writer io.Writer = f
+	if compress {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		writer = gz
+	}
+	for n := 0; n < numDocs; n++ {
+		fmt.Fprintf(writer, "{\"id\":\"%d\",\"name\":\"doc-%d\"}\n", n, n)
+	}
+}
+
+func TestIngestSplitFile(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "deluge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "data.json")
+	writeTestFile(t, path, 1000, false)
+	writeTestFile(t, filepath.Join(dir, "data.json.gz"), 100, true)
+	ingestor := newTestIngestor(t, client, newSplitInput(t, path, 1000))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if server.NumDocs(testIndex) != 1000 {
+		t.Errorf("expected 1000 documents, got %d", server.NumDocs(testIndex))
+	}
+	if len(ingestor.DocErrs()) != 0 {
+		t.Errorf("expected no document errors, got %v", ingestor.DocErrs())
+	}
+	// compressed files are not split
+	ingestor = newTestIngestor(t, client, newSplitInput(t, path+".gz", 100),
+		deluge.SetCompression("gzip"))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if server.NumDocs(testIndex) != 100 {
+		t.Errorf("expected 100 documents, got %d", server.NumDocs(testIndex))
+	}
+}
+
+func newSplitInput(t *testing.T, path string, splitSize int64) deluge.Input {
+	in, err := deluge.NewFileInput([]string{path}, nil, input.SetSplitSize(splitSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return in
+}
+
+func TestIngestSplitFileCompressed(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "deluge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// a compressed file without a compressed extension cannot be detected
+	path := filepath.Join(dir, "data.json")
+	writeTestFile(t, path, 1000, true)
+	ingestor := newTestIngestor(t, client, newSplitInput(t, path, 1000),
+		deluge.SetCompression("gzip"))
+	err = ingestor.Ingest()
+	if err == nil || !strings.Contains(err.Error(), "cannot be decompressed") {
+		t.Errorf("expected split file decompression error, got %v", err)
+	}
+	// the first segment is detected as compressed by its magic bytes
+	ingestor = newTestIngestor(t, client, newSplitInput(t, path, 1000),
+		deluge.SetCompression(deluge.CompressionAuto))
+	err = ingestor.Ingest()
+	if err == nil || !strings.Contains(err.Error(), "cannot be decompressed") {
+		t.Errorf("expected split file decompression error, got %v", err)
+	}
+}
+
+func TestIngestAutoCompression(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "deluge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writers := map[string]func(io.Writer) io.WriteCloser{
+		"plain.json": nil,
+		"ext.json.gz": func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		},
+		"ext.json.deflate": func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		},
+		// detected by magic bytes
+		"sniffed-gzip.json": func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		},
+		"sniffed-zlib.json": func(w io.Writer) io.WriteCloser {
+			return zlib.NewWriter(w)
+		},
+	}
+	n := 0
+	for name, ctor := range writers {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var w io.Writer = f
+		var wc io.WriteCloser
+		if ctor != nil {
+			wc = ctor(f)
+			w = wc
+		}
+		for end := n + 100; n < end; n++ {
+			fmt.Fprintf(w, "{\"id\":\"%d\",\"name\":\"doc-%d\"}\n", n, n)
+		}
+		if wc != nil {
+			wc.Close()
+		}
+		f.Close()
+	}
+	in, err := deluge.NewFileInput([]string{dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ingestor := newTestIngestor(t, client, in,
+		deluge.SetCompression(deluge.CompressionAuto))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if server.NumDocs(testIndex) != 500 {
+		t.Errorf("expected 500 documents, got %d", server.NumDocs(testIndex))
+	}
+	if len(ingestor.DocErrs()) != 0 {
+		t.Errorf("expected no document errors, got %v", ingestor.DocErrs())
+	}
+}
+
+func TestIngestAutoCompressionUnseekable(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	compress := func(ctor func(io.Writer) io.WriteCloser, lines string) string {
+		buf := &bytes.Buffer{}
+		w := ctor(buf)
+		w.Write([]byte(lines))
+		w.Close()
+		return buf.String()
+	}
+	lines := func(start int) string {
+		str := ""
+		for n := start; n < start+100; n++ {
+			str += fmt.Sprintf("{\"id\":\"%d\",\"name\":\"doc-%d\"}\n", n, n)
+		}
+		return str
+	}
+	in := &testInput{
+		sources: []string{
+			compress(func(w io.Writer) io.WriteCloser {
+				return gzip.NewWriter(w)
+			}, lines(0)),
+			compress(func(w io.Writer) io.WriteCloser {
+				return zlib.NewWriter(w)
+			}, lines(100)),
+			lines(200),
+			// plain text with a valid zlib header checksum, but a preset
+			// dictionary flag, is not zlib
+			"800,foo\n" + lines(300),
+		},
+		unseekable: true,
+	}
+	ingestor := newTestIngestor(t, client, in,
+		deluge.SetCompression(deluge.CompressionAuto),
+		deluge.SetErrorThreshold(1))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if server.NumDocs(testIndex) != 400 {
+		t.Errorf("expected 400 documents, got %d", server.NumDocs(testIndex))
+	}
+	if len(ingestor.DocErrs()) != 1 {
+		t.Errorf("expected 1 document error, got %v", ingestor.DocErrs())
+	}
+}
+
+func TestIngestOperations(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	for n := 0; n < 4; n++ {
+		err := server.PutDocument(testIndex, fmt.Sprintf("%d", n), map[string]string{
+			"name": fmt.Sprintf("doc-%d", n),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	input := &testInput{
+		sources: []string{
+			`{"id":"0","op":"delete"}
+{"id":"1","op":"update","name":"updated"}
+{"id":"2","op":"upsert","name":"upserted"}
+{"id":"4","op":"upsert","name":"inserted"}
+{"id":"3","op":"script","name":"scripted"}
+{"id":"5","op":"create","name":"created"}
+{"id":"6","name":"indexed"}
+{"id":"7","op":"merge","name":"unsupported"}
+`,
+		},
+	}
+	ingestor := newTestIngestor(t, client, input,
+		deluge.SetDocument(newOpDocument),
+		deluge.ClearExistingIndex(false))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.Document(testIndex, "0"); ok {
+		t.Error("expected document `0` to be deleted")
+	}
+	expected := map[string]string{
+		"1": `{"id":"1","name":"updated"}`,
+		"2": `{"id":"2","name":"upserted"}`,
+		"3": `{"name":"doc-3"}`,
+		"4": `{"id":"4","name":"inserted"}`,
+		"5": `{"id":"5","name":"created"}`,
+		"6": `{"id":"6","name":"indexed"}`,
+	}
+	for id, src := range expected {
+		source, ok := server.Document(testIndex, id)
+		if !ok || string(source) != src {
+			t.Errorf("expected document `%s` source %s, got %s", id, src, source)
+		}
+	}
+	if _, ok := server.Document(testIndex, "7"); ok {
+		t.Error("expected document with an unsupported operation to be skipped")
+	}
+	if len(ingestor.DocErrs()) != 1 {
+		t.Errorf("expected 1 document error, got %v", ingestor.DocErrs())
+	}
+}
+
+func TestIngestMetadata(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(2, 100, nil),
+		deluge.SetDocument(newRoutedDocument))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if server.NumDocs(testIndex) != 100 {
+		t.Errorf("expected 100 documents, got %d", server.NumDocs(testIndex))
+	}
+	meta, ok := server.DocumentMetadata(testIndex, "42")
+	if !ok || meta.Routing != "doc-42" || meta.Version != 10 {
+		t.Errorf("unexpected metadata %v", meta)
+	}
+}
+
+func TestIngestDocumentIndex(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	if err := server.PutDocument(testIndex+"-2020.01", "existing", map[string]string{"name": "existing"}); err != nil {
+		t.Fatal(err)
+	}
+	ingestor := newTestIngestor(t, client, newTestInput(4, 300, nil),
+		deluge.SetDocument(newMonthlyDocument),
+		deluge.SetNumReplicas(2),
+		deluge.SetReadOnly(true))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.Document(testIndex+"-2020.01", "existing"); !ok {
+		t.Error("expected the existing document to be kept")
+	}
+	if server.NumDocs(testIndex+"-2020.01") != 101 {
+		t.Errorf("expected 101 documents, got %d", server.NumDocs(testIndex+"-2020.01"))
+	}
+	for _, index := range []string{testIndex, testIndex + "-2020.01", testIndex + "-2020.02", testIndex + "-2020.03"} {
+		if server.Setting(index, "number_of_replicas") != "2" {
+			t.Errorf("expected index `%s` to have 2 replicas, got %s", index, server.Setting(index, "number_of_replicas"))
+		}
+		if server.Setting(index, "blocks.read_only") != "true" {
+			t.Errorf("expected index `%s` to be read only", index)
+		}
+	}
+	for _, index := range []string{testIndex + "-2020.02", testIndex + "-2020.03"} {
+		if server.Mapping(index) != testMapping {
+			t.Errorf("expected index `%s` to have mapping %s, got %s", index, testMapping, server.Mapping(index))
+		}
+		if server.NumDocs(index) != 100 {
+			t.Errorf("expected index `%s` to have 100 documents, got %d", index, server.NumDocs(index))
+		}
+	}
+	if server.NumDocs(testIndex) != 0 {
+		t.Errorf("expected no documents in the target index, got %d", server.NumDocs(testIndex))
+	}
+}
+
+// failingClient fails to create the provided index.
+type failingClient struct {
+	*elastic.Client
+	index string
+}
+
+func (c *failingClient) CreateIndex(index string, mapping string, settings string) error {
+	if index == c.index {
+		return errors.New("no available nodes")
+	}
+	return c.Client.CreateIndex(index, mapping, settings)
+}
+
+func TestIngestDocumentIndexError(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	failing := &failingClient{
+		Client: client,
+		index:  testIndex + "-2020.02",
+	}
+	ingestor := newTestIngestor(t, failing, newTestInput(4, 300, nil),
+		deluge.SetDocument(newMonthlyDocument),
+		deluge.SetErrorThreshold(1))
+	err := ingestor.Ingest()
+	if err == nil || !strings.Contains(err.Error(), "no available nodes") {
+		t.Fatalf("expected the index creation error to fail the ingest, got %v", err)
+	}
+	if len(ingestor.DocErrs()) != 0 {
+		t.Errorf("expected no document errors, got %v", ingestor.DocErrs())
+	}
+}
+
+func TestIngestIndexTemplate(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(1, 10, nil),
+		deluge.AddComponentTemplate("shards", `{"template":{"settings":{"number_of_shards":3}}}`),
+		deluge.SetLifecyclePolicy("rollover", `{"policy":{"phases":{"hot":{"actions":{"rollover":{"max_age":"1d"}}}}}}`),
+		deluge.SetIndexTemplate(&deluge.IndexTemplate{
+			Name:       testIndex,
+			Patterns:   []string{testIndex + "-*"},
+			ComposedOf: []string{"shards"},
+			Settings:   `{"number_of_replicas":2}`,
+		}))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.LifecyclePolicy("rollover"); !ok {
+		t.Error("expected lifecycle policy `rollover` to exist")
+	}
+	// indices created by the cluster receive the document mapping
+	index := testIndex + "-000001"
+	server.CreateIndex(index)
+	if server.Mapping(index) != testMapping {
+		t.Errorf("expected mapping %s, got %s", testMapping, server.Mapping(index))
+	}
+	expected := map[string]string{
+		"number_of_shards":   "3",
+		"number_of_replicas": "2",
+		"lifecycle.name":     "rollover",
+	}
+	for key, value := range expected {
+		if server.Setting(index, key) != value {
+			t.Errorf("expected setting `%s` to be %s, got %s", key, value, server.Setting(index, key))
+		}
+	}
+}
+
+func TestIngestIndexTemplateUnsupported(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	// hide the template methods of the client
+	unsupported := struct {
+		deluge.Client
+	}{client}
+	ingestor := newTestIngestor(t, unsupported, newTestInput(1, 10, nil),
+		deluge.SetIndexTemplate(&deluge.IndexTemplate{
+			Name:     testIndex,
+			Patterns: []string{testIndex + "-*"},
+		}))
+	if err := ingestor.Ingest(); err == nil {
+		t.Error("expected an error for a client without template support")
+	}
+}
+
+// settingsClient records the settings of every created index.
+type settingsClient struct {
+	*elastic.Client
+	created []string
+}
+
+func (c *settingsClient) CreateIndex(index string, mapping string, settings string) error {
+	c.created = append(c.created, settings)
+	return c.Client.CreateIndex(index, mapping, settings)
+}
+
+func TestIngestTuneSettings(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	recorder := &settingsClient{Client: client}
+	ingestor := newTestIngestor(t, recorder, newTestInput(1, 10, nil),
+		deluge.SetIndexSettings(`{"index":{"refresh_interval":"30s","number_of_shards":3}}`),
+		deluge.SetTuneSettings(true))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.created) != 1 {
+		t.Fatalf("expected 1 created index, got %d", len(recorder.created))
+	}
+	created := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(recorder.created[0]), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created["index.refresh_interval"] != "-1" || created["index.translog.durability"] != "async" {
+		t.Errorf("expected the index to be created with tuned settings, got %v", created)
+	}
+	expected := map[string]string{
+		"number_of_shards":                 "3",
+		"number_of_replicas":               "1",
+		"refresh_interval":                 "30s",
+		"translog.durability":              "",
+		"merge.scheduler.max_thread_count": "",
+	}
+	for key, value := range expected {
+		if server.Setting(testIndex, key) != value {
+			t.Errorf("expected setting `%s` to be `%s`, got `%s`", key, value, server.Setting(testIndex, key))
+		}
+	}
+}
+
+func TestIngestTuneSettingsRestoredOnFailure(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(4, 300, func(n int) bool {
+		return n%10 != 0
+	}), deluge.SetDocument(newMonthlyDocument),
+		deluge.SetIndexSettings(`{"index":{"refresh_interval":"30s"}}`),
+		deluge.SetTuneSettings(true),
+		deluge.SetErrorThreshold(0.05))
+	if err := ingestor.Ingest(); err == nil {
+		t.Fatal("expected the error threshold to fail the ingest")
+	}
+	indices := server.Indices()
+	if len(indices) < 2 {
+		t.Fatalf("expected the target and a document index, got %v", indices)
+	}
+	for _, index := range indices {
+		if server.Setting(index, "refresh_interval") != "30s" {
+			t.Errorf("expected index `%s` refresh interval to be restored, got `%s`",
+				index, server.Setting(index, "refresh_interval"))
+		}
+		if server.Setting(index, "translog.durability") != "" {
+			t.Errorf("expected index `%s` translog durability to be restored, got `%s`",
+				index, server.Setting(index, "translog.durability"))
+		}
+	}
+}
+
+func TestIngestForceMerge(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(1, 10, nil),
+		deluge.SetRefresh(true),
+		deluge.SetForceMerge(1),
+		deluge.SetWaitForGreen(time.Second),
+		deluge.SetReadOnly(true))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if server.NumRequests("POST", "/"+testIndex+"/_refresh") != 1 {
+		t.Error("expected the index to be refreshed")
+	}
+	if server.MaxNumSegments(testIndex) != 1 {
+		t.Errorf("expected the index to be merged to 1 segment, got %d", server.MaxNumSegments(testIndex))
+	}
+	if server.NumRequests("GET", "/_cluster/health/"+testIndex) != 1 {
+		t.Error("expected to wait for the index health")
+	}
+}
+
+func TestIngestWaitForGreenTimeout(t *testing.T) {
+	server, client := newTestClient(t, estest.SetHealth("yellow"))
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(1, 10, nil),
+		deluge.SetWaitForGreen(10*time.Millisecond))
+	if err := ingestor.Ingest(); err == nil {
+		t.Error("expected an error waiting for a yellow index")
+	}
+	if server.NumDocs(testIndex) != 10 {
+		t.Errorf("expected 10 documents, got %d", server.NumDocs(testIndex))
+	}
+}
+
+func TestIngestSnapshot(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(1, 10, nil),
+		deluge.SetSnapshot("backups", "/mnt/backups", ""))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.SnapshotRepository("backups"); !ok {
+		t.Fatal("expected the snapshot repository to be registered")
+	}
+	status := ingestor.SnapshotStatus()
+	if status == nil || !status.Succeeded() || !strings.HasPrefix(status.Snapshot, testIndex+"-") {
+		t.Fatalf("expected a successful snapshot named after `%s`, got %v", testIndex, status)
+	}
+	first := status.Snapshot
+	if indices := server.SnapshotIndices("backups", first); len(indices) != 1 || indices[0] != testIndex {
+		t.Errorf("expected the snapshot to contain `%s`, got %v", testIndex, indices)
+	}
+	// re-ingesting with the default name takes a new snapshot
+	ingestor = newTestIngestor(t, client, newTestInput(1, 10, nil),
+		deluge.SetSnapshot("backups", "", ""))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	status = ingestor.SnapshotStatus()
+	if status == nil || !status.Succeeded() || status.Snapshot == first {
+		t.Fatalf("expected a second successful snapshot, got %v", status)
+	}
+	// the repository is reused by subsequent ingests
+	ingestor = newTestIngestor(t, client, newTestInput(1, 10, nil),
+		deluge.SetSnapshot("backups", "", "second"))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if server.NumRequests("PUT", "/_snapshot/backups") != 1 {
+		t.Error("expected the snapshot repository to be registered once")
+	}
+	if len(server.SnapshotIndices("backups", "second")) != 1 {
+		t.Error("expected the named snapshot to be taken")
+	}
+}
+
+func TestIngestSnapshotFailed(t *testing.T) {
+	server, client := newTestClient(t, estest.SetSnapshotState("PARTIAL"))
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(1, 10, nil),
+		deluge.SetSnapshot("backups", "/mnt/backups", "snap"))
+	if err := ingestor.Ingest(); err == nil {
+		t.Error("expected an error for a partial snapshot")
+	}
+	status := ingestor.SnapshotStatus()
+	if status == nil || status.State != "PARTIAL" || status.FailedShards != 1 {
+		t.Errorf("expected a partial snapshot status, got %v", status)
+	}
+	// a missing repository without a location cannot be registered
+	ingestor = newTestIngestor(t, client, newTestInput(1, 10, nil),
+		deluge.SetSnapshot("missing", "", "snap"))
+	if err := ingestor.Ingest(); err == nil {
+		t.Error("expected an error for a missing repository")
+	}
+	if ingestor.SnapshotStatus() != nil {
+		t.Error("expected no snapshot status")
+	}
+}
+
+func TestIngestVerify(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	// the last two documents reuse the ids of earlier documents
+	input := newTestInput(1, 10, nil)
+	input.sources[0] += "{\"id\":\"1\",\"name\":\"dup-1\"}\n{\"id\":\"2\",\"name\":\"dup-2\"}\n"
+	ingestor := newTestIngestor(t, client, input,
+		deluge.SetVerify(true))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+	summaries := ingestor.VerifySummaries()
+	if len(summaries) != 1 {
+		t.Fatalf("expected 1 verification summary, got %d", len(summaries))
+	}
+	summary := summaries[0]
+	if summary.NumSubmitted != 12 || summary.NumUnique != 10 || summary.NumDuplicates != 2 ||
+		summary.NumDocs != 10 || summary.NumMissing != 0 || summary.NumExtra != 0 {
+		t.Errorf("unexpected verification summary: %v", summary)
+	}
+}
+
+func TestIngestVerifyMissing(t *testing.T) {
+	server, client := newTestClient(t, estest.SetDroppedItems(2))
+	defer server.Close()
+	ingestor := newTestIngestor(t, client, newTestInput(1, 10, nil),
+		deluge.SetVerify(true))
+	if err := ingestor.Ingest(); err == nil {
+		t.Error("expected an error for missing documents")
+	}
+	summaries := ingestor.VerifySummaries()
+	if len(summaries) != 1 || summaries[0].NumMissing != 2 || summaries[0].NumDocs != 8 {
+		t.Fatalf("expected 2 missing documents, got %v", summaries)
+	}
+	// the missing documents are within the tolerance
+	server, client = newTestClient(t, estest.SetDroppedItems(2))
+	defer server.Close()
+	ingestor = newTestIngestor(t, client, newTestInput(1, 10, nil),
+		deluge.SetVerify(true),
+		deluge.SetVerifyTolerance(0.2))
+	if err := ingestor.Ingest(); err != nil {
+		t.Fatal(err)
+	}
+}
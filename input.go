@@ -2,10 +2,14 @@ package deluge
 
 import (
 	"io"
+	"os"
 
+	"github.com/unchartedsoftware/deluge/input"
 	"github.com/unchartedsoftware/deluge/input/elastic"
 	"github.com/unchartedsoftware/deluge/input/file"
 	"github.com/unchartedsoftware/deluge/input/hdfs"
+	"github.com/unchartedsoftware/deluge/input/s3"
+	"github.com/unchartedsoftware/deluge/input/stream"
 )
 
 // Input represents an input type for processing.
@@ -20,11 +24,29 @@ func NewElasticInput(client elastic.Client, index string, scanSize int) (Input,
 }
 
 // NewFileInput instantiates a new instance of a file input.
-func NewFileInput(paths []string, excludes []string) (Input, error) {
-	return file.NewInput(paths, excludes)
+func NewFileInput(paths []string, excludes []string, options ...input.OptionFunc) (Input, error) {
+	return file.NewInput(paths, excludes, options...)
 }
 
 // NewHDFSInput instantiates a new instance of a hdfs input.
-func NewHDFSInput(client hdfs.Client, paths []string, excludes []string) (Input, error) {
-	return hdfs.NewInput(client, paths, excludes)
+func NewHDFSInput(client hdfs.Client, paths []string, excludes []string, options ...input.OptionFunc) (Input, error) {
+	return hdfs.NewInput(client, paths, excludes, options...)
+}
+
+// NewS3Input instantiates a new instance of an S3 input.
+func NewS3Input(client s3.Client, paths []string, excludes []string, options ...input.OptionFunc) (Input, error) {
+	return s3.NewInput(client, paths, excludes, options...)
+}
+
+// NewStreamInput instantiates a new instance of a stream input, splitting the
+// uncompressed newline delimited stream into chunks of at least chunkSize
+// bytes.
+func NewStreamInput(reader io.Reader, name string, chunkSize int) (Input, error) {
+	return stream.NewInput(reader, name, chunkSize)
+}
+
+// NewStdinInput instantiates a new instance of a stream input reading from
+// stdin.
+func NewStdinInput(chunkSize int) (Input, error) {
+	return stream.NewInput(os.Stdin, "stdin", chunkSize)
 }
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 
+	"github.com/unchartedsoftware/deluge/input"
 	"github.com/unchartedsoftware/deluge/util"
 )
 
@@ -14,6 +15,8 @@ type Input struct {
 	paths   []string
 	sources []*Source
 	index   int
+	options *input.Options
+	offset  int64
 }
 
 // Source represents a filesystem file source.
@@ -22,7 +25,9 @@ type Source struct {
 	fullpath string
 }
 
-func getInfo(path string, excludes []string) ([]*Source, error) {
+// getInfo returns the sources within the path, where rel is the path relative
+// to the input path, or empty if it is the input path.
+func getInfo(path string, rel string, excludes []string, opts *input.Options) ([]*Source, error) {
 	// get info on path
 	info, err := os.Stat(path)
 	if err != nil {
@@ -30,15 +35,20 @@ func getInfo(path string, excludes []string) ([]*Source, error) {
 	}
 	// data to populate
 	var sources []*Source
+	// files given as the input path are filtered by name
+	name := rel
+	if name == "" {
+		name = info.Name()
+	}
 	// check if dir
-	if util.IsValidFile(info, excludes) {
+	if util.IsValidFile(info, excludes) && opts.IsIncludedFile(name, info) {
 		// is file
 		sources = append(sources, &Source{
 			file:     info,
 			fullpath: path,
 		})
 	}
-	if util.IsValidDir(info, excludes) {
+	if util.IsValidDir(info, excludes) && (rel == "" || opts.IsIncludedDir(rel)) {
 		// is directory
 		infos, err := ioutil.ReadDir(path)
 		if err != nil {
@@ -46,10 +56,14 @@ func getInfo(path string, excludes []string) ([]*Source, error) {
 		}
 		// for each file / dir
 		for _, info := range infos {
-			// get full path
+			// get full and relative path
 			fullpath := path + "/" + info.Name()
+			childRel := info.Name()
+			if rel != "" {
+				childRel = rel + "/" + info.Name()
+			}
 			// depth-first traversal into sub directories
-			children, err := getInfo(fullpath, excludes)
+			children, err := getInfo(fullpath, childRel, excludes, opts)
 			if err != nil {
 				return nil, err
 			}
@@ -61,10 +75,14 @@ func getInfo(path string, excludes []string) ([]*Source, error) {
 }
 
 // NewInput instantiates a new instance of a file input.
-func NewInput(paths []string, excludes []string) (*Input, error) {
+func NewInput(paths []string, excludes []string, options ...input.OptionFunc) (*Input, error) {
+	opts, err := input.NewOptions(options...)
+	if err != nil {
+		return nil, err
+	}
 	var sources []*Source
 	for _, path := range paths {
-		srcs, err := getInfo(path, excludes)
+		srcs, err := getInfo(path, "", excludes, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -74,10 +92,12 @@ func NewInput(paths []string, excludes []string) (*Input, error) {
 		paths:   paths,
 		sources: sources,
 		index:   0,
+		options: opts,
 	}, nil
 }
 
-// Next opens the file and returns the reader.
+// Next opens the file and returns the reader. Files larger than the split
+// size are returned as a sequence of segments.
 func (i *Input) Next() (io.Reader, error) {
 	if i.index > len(i.sources)-1 {
 		return nil, io.EOF
@@ -87,8 +107,24 @@ func (i *Input) Next() (io.Reader, error) {
 	if err != nil {
 		return nil, err
 	}
-	i.index++
-	return reader, nil
+	size := source.file.Size()
+	if !i.options.ShouldSplit(source.fullpath, size) {
+		i.index++
+		return input.NewReader(reader, source.fullpath), nil
+	}
+	segment, err := input.NextSegment(reader, source.fullpath, i.offset, i.options.SplitSize, size)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	if segment.End() < size {
+		// the next segment of the file is returned on the next call
+		i.offset = segment.End()
+	} else {
+		i.index++
+		i.offset = 0
+	}
+	return segment, nil
 }
 
 // Summary returns a string containing summary information.
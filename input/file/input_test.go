@@ -0,0 +1,132 @@
+package file
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/unchartedsoftware/deluge/input"
+)
+
+func writeTestFile(t *testing.T, path string, numDocs int) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for n := 0; n < numDocs; n++ {
+		fmt.Fprintf(f, "{\"id\":\"%d\",\"name\":\"doc-%d\"}\n", n, n)
+	}
+}
+
+func sourceNames(t *testing.T, in *Input) []string {
+	var names []string
+	for {
+		next, err := in.Next()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader := next.(*input.Reader)
+		reader.Close()
+		names = append(names, reader.Name())
+	}
+}
+
+func TestInputFilters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deluge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]int{
+		"part-0.json.gz":         10,
+		"part-1.json.gz":         1000,
+		"part-2.json":            10,
+		"_SUCCESS":               1,
+		"a/part-3.json.gz":       10,
+		"a/_SUCCESS":             1,
+		"a/part-4.json.gz.tmp":   10,
+		"a/b/part-5.json.gz":     10,
+		"a/b/part-6.json.gz":     10,
+		"staging/part-7.json.gz": 10,
+	}
+	for name, numDocs := range files {
+		writeTestFile(t, filepath.Join(dir, name), numDocs)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "a/b/part-6.json.gz"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	in, err := NewInput([]string{dir}, nil,
+		input.SetIncludes("**/part-*.json.gz"),
+		input.SetExcludeGlobs("staging"),
+		input.SetExcludeRegexps(`\.tmp$`),
+		input.SetModifiedAfter(time.Now().Add(-24*time.Hour)),
+		input.SetMaxSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := sourceNames(t, in)
+	expected := []string{
+		dir + "/a/b/part-5.json.gz",
+		dir + "/a/part-3.json.gz",
+		dir + "/part-0.json.gz",
+	}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected sources %v, got %v", expected, names)
+	}
+	// patterns are matched against the whole relative path
+	in, err = NewInput([]string{dir}, nil,
+		input.SetExcludeGlobs("_SUCCESS", "a/**/*.gz", "**/*.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names = sourceNames(t, in)
+	expected = []string{
+		dir + "/a/_SUCCESS",
+		dir + "/part-0.json.gz",
+		dir + "/part-1.json.gz",
+		dir + "/part-2.json",
+		dir + "/staging/part-7.json.gz",
+	}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected sources %v, got %v", expected, names)
+	}
+	in, err = NewInput([]string{dir}, nil,
+		input.SetMinSize(64),
+		input.SetMaxSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names = sourceNames(t, in)
+	if len(names) != 7 {
+		t.Errorf("expected 7 sources within the size limits, got %v", names)
+	}
+}
+
+func TestInputInvalidFilters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deluge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	_, err = NewInput([]string{dir}, nil, input.SetExcludeRegexps("("))
+	if err == nil {
+		t.Errorf("expected invalid expression error")
+	}
+	_, err = NewInput([]string{dir}, nil, input.SetIncludes("a/["))
+	if err == nil {
+		t.Errorf("expected invalid glob error")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 
+	"github.com/unchartedsoftware/deluge/input"
 	"github.com/unchartedsoftware/deluge/util"
 )
 
@@ -22,6 +23,8 @@ type Input struct {
 	paths    []string
 	sources  []*Source
 	index    int
+	options  *input.Options
+	offset   int64
 }
 
 // Source represents an HDFS file source.
@@ -30,7 +33,9 @@ type Source struct {
 	fullpath string
 }
 
-func getInfo(client Client, path string, excludes []string) ([]*Source, error) {
+// getInfo returns the sources within the path, where rel is the path relative
+// to the input path, or empty if it is the input path.
+func getInfo(client Client, path string, rel string, excludes []string, opts *input.Options) ([]*Source, error) {
 	// get info on path
 	info, err := client.Stat(path)
 	if err != nil {
@@ -38,15 +43,20 @@ func getInfo(client Client, path string, excludes []string) ([]*Source, error) {
 	}
 	// data to populate
 	var sources []*Source
+	// files given as the input path are filtered by name
+	name := rel
+	if name == "" {
+		name = info.Name()
+	}
 	// check if dir
-	if util.IsValidFile(info, excludes) {
+	if util.IsValidFile(info, excludes) && opts.IsIncludedFile(name, info) {
 		// is file
 		sources = append(sources, &Source{
 			file:     info,
 			fullpath: path,
 		})
 	}
-	if util.IsValidDir(info, excludes) {
+	if util.IsValidDir(info, excludes) && (rel == "" || opts.IsIncludedDir(rel)) {
 		// is directory
 		infos, err := client.ReadDir(path)
 		if err != nil {
@@ -54,10 +64,14 @@ func getInfo(client Client, path string, excludes []string) ([]*Source, error) {
 		}
 		// for each file / dir
 		for _, info := range infos {
-			// get full path
+			// get full and relative path
 			fullpath := path + "/" + info.Name()
+			childRel := info.Name()
+			if rel != "" {
+				childRel = rel + "/" + info.Name()
+			}
 			// depth-first traversal into sub directories
-			children, err := getInfo(client, fullpath, excludes)
+			children, err := getInfo(client, fullpath, childRel, excludes, opts)
 			if err != nil {
 				return nil, err
 			}
@@ -69,10 +83,14 @@ func getInfo(client Client, path string, excludes []string) ([]*Source, error) {
 }
 
 // NewInput instantiates a new instance of a file input.
-func NewInput(client Client, paths []string, excludes []string) (*Input, error) {
+func NewInput(client Client, paths []string, excludes []string, options ...input.OptionFunc) (*Input, error) {
+	opts, err := input.NewOptions(options...)
+	if err != nil {
+		return nil, err
+	}
 	var sources []*Source
 	for _, path := range paths {
-		srcs, err := getInfo(client, path, excludes)
+		srcs, err := getInfo(client, path, "", excludes, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -83,10 +101,13 @@ func NewInput(client Client, paths []string, excludes []string) (*Input, error)
 		client:  client,
 		sources: sources,
 		index:   0,
+		options: opts,
 	}, nil
 }
 
-// Next opens the file and returns the reader.
+// Next opens the file and returns the reader. Files larger than the split
+// size are returned as a sequence of segments if the client supports random
+// access reads.
 func (i *Input) Next() (io.Reader, error) {
 	if i.index > len(i.sources)-1 {
 		return nil, io.EOF
@@ -96,8 +117,27 @@ func (i *Input) Next() (io.Reader, error) {
 	if err != nil {
 		return nil, err
 	}
-	i.index++
-	return reader, nil
+	size := source.file.Size()
+	readerAt, ok := reader.(io.ReaderAt)
+	if !ok || !i.options.ShouldSplit(source.fullpath, size) {
+		i.index++
+		return input.NewReader(reader, source.fullpath), nil
+	}
+	segment, err := input.NextSegment(readerAt, source.fullpath, i.offset, i.options.SplitSize, size)
+	if err != nil {
+		if closer, ok := reader.(io.Closer); ok {
+			closer.Close()
+		}
+		return nil, err
+	}
+	if segment.End() < size {
+		// the next segment of the file is returned on the next call
+		i.offset = segment.End()
+	} else {
+		i.index++
+		i.offset = 0
+	}
+	return segment, nil
 }
 
 // Summary returns a string containing summary information.
@@ -1 +1,51 @@
 package input
+
+import (
+	"errors"
+	"io"
+)
+
+// NamedReader represents an io.Reader that knows the name of the source it
+// is reading from.
+type NamedReader interface {
+	io.Reader
+	Name() string
+}
+
+// Reader wraps an io.Reader with the name of its source. Close and Seek are
+// forwarded to the underlying reader if it supports them.
+type Reader struct {
+	io.Reader
+	name string
+}
+
+// NewReader instantiates a new named reader.
+func NewReader(reader io.Reader, name string) *Reader {
+	return &Reader{
+		Reader: reader,
+		name:   name,
+	}
+}
+
+// Name returns the name of the source.
+func (r *Reader) Name() string {
+	return r.name
+}
+
+// Close closes the underlying reader if it is an io.Closer.
+func (r *Reader) Close() error {
+	closer, ok := r.Reader.(io.Closer)
+	if !ok {
+		return nil
+	}
+	return closer.Close()
+}
+
+// Seek seeks the underlying reader if it is an io.Seeker.
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := r.Reader.(io.Seeker)
+	if !ok {
+		return 0, errors.New("Underlying reader does not support seeking")
+	}
+	return seeker.Seek(offset, whence)
+}
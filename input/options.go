@@ -0,0 +1,211 @@
+package input
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/unchartedsoftware/deluge/util"
+)
+
+// Options represents the options of a file system input.
+type Options struct {
+	// SplitSize is the size in bytes above which files are split into
+	// segments. Files are not split if it is zero.
+	SplitSize int64
+	// Includes are the glob patterns of which a file path must match at
+	// least one, relative to the input path. All files are included if there
+	// are none.
+	Includes []string
+	// ExcludeGlobs are the glob patterns of file and directory paths to
+	// exclude, relative to the input path.
+	ExcludeGlobs []string
+	// ExcludeRegexps are the regular expressions of file and directory paths
+	// to exclude, relative to the input path.
+	ExcludeRegexps []*regexp.Regexp
+	// ModifiedAfter excludes files last modified at or before the time, if
+	// it is set.
+	ModifiedAfter time.Time
+	// ModifiedBefore excludes files last modified at or after the time, if it
+	// is set.
+	ModifiedBefore time.Time
+	// MinSize excludes files smaller than the size in bytes.
+	MinSize int64
+	// MaxSize excludes files larger than the size in bytes, if it is not
+	// zero.
+	MaxSize int64
+}
+
+// OptionFunc is a function that configures the options of a file system
+// input.
+type OptionFunc func(*Options) error
+
+// SetSplitSize sets the size in bytes above which uncompressed files are split
+// into newline aligned segments that can be read by separate workers. Files
+// are not split by default.
+func SetSplitSize(size int64) OptionFunc {
+	return func(o *Options) error {
+		if size < 0 {
+			return fmt.Errorf("Invalid split size %d", size)
+		}
+		o.SplitSize = size
+		return nil
+	}
+}
+
+// SetIncludes sets the glob patterns of which a file path, relative to the
+// input path, must match at least one to be read. A `**` segment matches zero
+// or more directories, ex. `**/part-*.json.gz`.
+func SetIncludes(patterns ...string) OptionFunc {
+	return func(o *Options) error {
+		for _, pattern := range patterns {
+			if err := util.ValidateGlob(pattern); err != nil {
+				return fmt.Errorf("Invalid include pattern `%s`: %v", pattern, err)
+			}
+		}
+		o.Includes = patterns
+		return nil
+	}
+}
+
+// SetExcludeGlobs sets the glob patterns of file and directory paths,
+// relative to the input path, to exclude. Excluded directories are not
+// traversed, ex. `**/_SUCCESS` or `**/*.tmp`.
+func SetExcludeGlobs(patterns ...string) OptionFunc {
+	return func(o *Options) error {
+		for _, pattern := range patterns {
+			if err := util.ValidateGlob(pattern); err != nil {
+				return fmt.Errorf("Invalid exclude pattern `%s`: %v", pattern, err)
+			}
+		}
+		o.ExcludeGlobs = patterns
+		return nil
+	}
+}
+
+// SetExcludeRegexps sets the regular expressions of file and directory paths,
+// relative to the input path, to exclude. Expressions are unanchored, so
+// `\.tmp$` excludes every path ending with `.tmp`.
+func SetExcludeRegexps(exprs ...string) OptionFunc {
+	return func(o *Options) error {
+		var regexps []*regexp.Regexp
+		for _, expr := range exprs {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return fmt.Errorf("Invalid exclude expression `%s`: %v", expr, err)
+			}
+			regexps = append(regexps, re)
+		}
+		o.ExcludeRegexps = regexps
+		return nil
+	}
+}
+
+// SetModifiedAfter excludes files last modified at or before the provided
+// time.
+func SetModifiedAfter(t time.Time) OptionFunc {
+	return func(o *Options) error {
+		o.ModifiedAfter = t
+		return nil
+	}
+}
+
+// SetModifiedBefore excludes files last modified at or after the provided
+// time.
+func SetModifiedBefore(t time.Time) OptionFunc {
+	return func(o *Options) error {
+		o.ModifiedBefore = t
+		return nil
+	}
+}
+
+// SetMinSize excludes files smaller than the provided size in bytes.
+func SetMinSize(size int64) OptionFunc {
+	return func(o *Options) error {
+		if size < 0 {
+			return fmt.Errorf("Invalid minimum file size %d", size)
+		}
+		o.MinSize = size
+		return nil
+	}
+}
+
+// SetMaxSize excludes files larger than the provided size in bytes.
+func SetMaxSize(size int64) OptionFunc {
+	return func(o *Options) error {
+		if size < 0 {
+			return fmt.Errorf("Invalid maximum file size %d", size)
+		}
+		o.MaxSize = size
+		return nil
+	}
+}
+
+// NewOptions instantiates and returns the options configured by the provided
+// option functions.
+func NewOptions(options ...OptionFunc) (*Options, error) {
+	opts := &Options{}
+	for _, option := range options {
+		if err := option(opts); err != nil {
+			return nil, err
+		}
+	}
+	if opts.MaxSize > 0 && opts.MaxSize < opts.MinSize {
+		return nil, fmt.Errorf("Maximum file size %d is less than minimum file size %d", opts.MaxSize, opts.MinSize)
+	}
+	return opts, nil
+}
+
+// ShouldSplit returns true if the file should be split into segments.
+func (o *Options) ShouldSplit(name string, size int64) bool {
+	return o.SplitSize > 0 && size > o.SplitSize && IsSplittable(name)
+}
+
+// isExcludedPath returns true if the relative path matches an exclude glob
+// or expression.
+func (o *Options) isExcludedPath(rel string) bool {
+	for _, pattern := range o.ExcludeGlobs {
+		if util.MatchGlob(pattern, rel) {
+			return true
+		}
+	}
+	for _, re := range o.ExcludeRegexps {
+		if re.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsIncludedDir returns true if the directory at the relative path should be
+// traversed.
+func (o *Options) IsIncludedDir(rel string) bool {
+	return !o.isExcludedPath(rel)
+}
+
+// IsIncludedFile returns true if the file at the relative path passes the
+// include, exclude, modification time and size filters.
+func (o *Options) IsIncludedFile(rel string, file os.FileInfo) bool {
+	if o.isExcludedPath(rel) {
+		return false
+	}
+	if !o.ModifiedAfter.IsZero() && !file.ModTime().After(o.ModifiedAfter) {
+		return false
+	}
+	if !o.ModifiedBefore.IsZero() && !file.ModTime().Before(o.ModifiedBefore) {
+		return false
+	}
+	if file.Size() < o.MinSize || (o.MaxSize > 0 && file.Size() > o.MaxSize) {
+		return false
+	}
+	if len(o.Includes) == 0 {
+		return true
+	}
+	for _, pattern := range o.Includes {
+		if util.MatchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
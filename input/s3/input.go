@@ -0,0 +1,210 @@
+package s3
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/unchartedsoftware/deluge/input"
+	"github.com/unchartedsoftware/deluge/util"
+)
+
+const scheme = "s3://"
+
+// Client represents an S3 client.
+type Client interface {
+	List(string, string) ([]*Object, error)
+	Stat(string, string) (*Object, error)
+	Open(string, string) (io.ReadCloser, error)
+}
+
+// Object represents an object stored in a bucket. It implements os.FileInfo,
+// where keys ending with a slash are directories.
+type Object struct {
+	key     string
+	size    int64
+	modTime time.Time
+}
+
+// NewObject instantiates a new object.
+func NewObject(key string, size int64, modTime time.Time) *Object {
+	return &Object{
+		key:     key,
+		size:    size,
+		modTime: modTime,
+	}
+}
+
+// Key returns the full key of the object.
+func (o *Object) Key() string {
+	return o.key
+}
+
+// Name returns the base name of the object key.
+func (o *Object) Name() string {
+	return path.Base(o.key)
+}
+
+// Size returns the length in bytes of the object.
+func (o *Object) Size() int64 {
+	return o.size
+}
+
+// Mode returns the file mode bits of the object.
+func (o *Object) Mode() os.FileMode {
+	if o.IsDir() {
+		return os.ModeDir | 0555
+	}
+	return 0444
+}
+
+// ModTime returns the last modification time of the object.
+func (o *Object) ModTime() time.Time {
+	return o.modTime
+}
+
+// IsDir returns whether or not the object is a directory placeholder.
+func (o *Object) IsDir() bool {
+	return strings.HasSuffix(o.key, "/")
+}
+
+// Sys returns nil.
+func (o *Object) Sys() interface{} {
+	return nil
+}
+
+// Input represents an S3 input type.
+type Input struct {
+	client  Client
+	paths   []string
+	sources []*Source
+	index   int
+}
+
+// Source represents an S3 object source.
+type Source struct {
+	bucket string
+	object *Object
+}
+
+// parsePath splits a path of the form `s3://bucket/key` into the bucket and
+// key. The scheme is optional.
+func parsePath(p string) (string, string) {
+	parts := strings.SplitN(strings.TrimPrefix(p, scheme), "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// isExcluded returns true if any directory of the path relative to the prefix
+// is to be excluded.
+func isExcluded(rel string, excludes []string, opts *input.Options) bool {
+	dirs := strings.Split(rel, "/")
+	for n, dir := range dirs[:len(dirs)-1] {
+		if util.ShouldExclude(dir, excludes) || !opts.IsIncludedDir(strings.Join(dirs[:n+1], "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func getInfo(client Client, p string, excludes []string, opts *input.Options) ([]*Source, error) {
+	bucket, key := parsePath(p)
+	if key != "" && !strings.HasSuffix(key, "/") {
+		// check if the path is an object
+		object, err := client.Stat(bucket, key)
+		if err == nil {
+			// objects given as the input path are filtered by name
+			if util.IsValidFile(object, excludes) && opts.IsIncludedFile(object.Name(), object) {
+				return []*Source{{bucket: bucket, object: object}}, nil
+			}
+			return nil, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		// otherwise it is a directory
+		key += "/"
+	}
+	// list every object under the prefix
+	objects, err := client.List(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, &os.PathError{
+			Op:   "list",
+			Path: p,
+			Err:  os.ErrNotExist,
+		}
+	}
+	var sources []*Source
+	for _, object := range objects {
+		rel := strings.TrimPrefix(object.Key(), key)
+		if util.IsValidFile(object, excludes) &&
+			!isExcluded(rel, excludes, opts) &&
+			opts.IsIncludedFile(rel, object) {
+			sources = append(sources, &Source{
+				bucket: bucket,
+				object: object,
+			})
+		}
+	}
+	return sources, nil
+}
+
+// NewInput instantiates a new instance of an S3 input. Paths are of the form
+// `s3://bucket/key`, where keys that do not name an object are treated as
+// prefixes and every object beneath them is read. Objects are filtered by
+// their keys relative to the prefix. Objects are never split, since they are
+// read as a stream.
+func NewInput(client Client, paths []string, excludes []string, options ...input.OptionFunc) (*Input, error) {
+	opts, err := input.NewOptions(options...)
+	if err != nil {
+		return nil, err
+	}
+	var sources []*Source
+	for _, p := range paths {
+		srcs, err := getInfo(client, p, excludes, opts)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, srcs...)
+	}
+	return &Input{
+		client:  client,
+		paths:   paths,
+		sources: sources,
+		index:   0,
+	}, nil
+}
+
+// Next opens the object and returns the reader.
+func (i *Input) Next() (io.Reader, error) {
+	if i.index > len(i.sources)-1 {
+		return nil, io.EOF
+	}
+	source := i.sources[i.index]
+	reader, err := i.client.Open(source.bucket, source.object.Key())
+	if err != nil {
+		return nil, err
+	}
+	i.index++
+	return input.NewReader(reader, scheme+source.bucket+"/"+source.object.Key()), nil
+}
+
+// Summary returns a string containing summary information.
+func (i *Input) Summary() string {
+	totalBytes := int64(0)
+	for _, source := range i.sources {
+		totalBytes += source.object.Size()
+	}
+	return fmt.Sprintf("Input %v contains %d objects containing %s",
+		i.paths,
+		len(i.sources),
+		util.FormatBytes(totalBytes))
+}
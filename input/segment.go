@@ -0,0 +1,112 @@
+package input
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// the size of the blocks read while searching for the end of a segment
+const segmentScanSize = 64 * 1024
+
+// compressed file extensions, whose contents cannot be split
+var compressedExtensions = map[string]bool{
+	".gz":      true,
+	".gzip":    true,
+	".bz2":     true,
+	".zz":      true,
+	".zlib":    true,
+	".deflate": true,
+	".z":       true,
+	".zip":     true,
+	".xz":      true,
+	".zst":     true,
+	".lz4":     true,
+	".snappy":  true,
+}
+
+// IsSplittable returns true if the named file can be split into segments,
+// which is not the case for compressed files.
+func IsSplittable(name string) bool {
+	return !compressedExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// Segment represents a newline aligned byte range of a source. Segments are
+// named by the source and their byte range, ex. `data.json@0-1048576`, so the
+// line numbers reported for a segment are relative to its start.
+type Segment struct {
+	*io.SectionReader
+	reader io.ReaderAt
+	source string
+	start  int64
+	end    int64
+}
+
+// NextSegment returns the segment of the source beginning at the provided
+// offset. The segment ends after the first newline found once it contains at
+// least splitSize bytes, or at the end of the source.
+func NextSegment(reader io.ReaderAt, source string, offset int64, splitSize int64, size int64) (*Segment, error) {
+	end, err := segmentEnd(reader, offset+splitSize, size)
+	if err != nil {
+		return nil, fmt.Errorf("Error occurred while splitting `%s`: %v", source, err)
+	}
+	return &Segment{
+		SectionReader: io.NewSectionReader(reader, offset, end-offset),
+		reader:        reader,
+		source:        source,
+		start:         offset,
+		end:           end,
+	}, nil
+}
+
+// segmentEnd returns the position after the first newline at or following
+// the byte preceding the provided offset.
+func segmentEnd(reader io.ReaderAt, offset int64, size int64) (int64, error) {
+	pos := offset - 1
+	buf := make([]byte, segmentScanSize)
+	for pos < size {
+		n, err := reader.ReadAt(buf, pos)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return pos + int64(i) + 1, nil
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		pos += int64(n)
+	}
+	return size, nil
+}
+
+// Name returns the name of the segment.
+func (s *Segment) Name() string {
+	return fmt.Sprintf("%s@%d-%d", s.source, s.start, s.end)
+}
+
+// Source returns the name of the source the segment belongs to.
+func (s *Segment) Source() string {
+	return s.source
+}
+
+// Start returns the byte offset of the start of the segment.
+func (s *Segment) Start() int64 {
+	return s.start
+}
+
+// End returns the byte offset of the end of the segment.
+func (s *Segment) End() int64 {
+	return s.end
+}
+
+// Close closes the underlying reader if it is an io.Closer.
+func (s *Segment) Close() error {
+	closer, ok := s.reader.(io.Closer)
+	if !ok {
+		return nil
+	}
+	return closer.Close()
+}
@@ -0,0 +1,115 @@
+package input
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func segmentAll(t *testing.T, data string, splitSize int64) []*Segment {
+	reader := strings.NewReader(data)
+	size := int64(len(data))
+	var segments []*Segment
+	for offset := int64(0); offset < size; {
+		segment, err := NextSegment(reader, "data.json", offset, splitSize, size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		segments = append(segments, segment)
+		offset = segment.End()
+	}
+	return segments
+}
+
+func TestNextSegment(t *testing.T) {
+	data := ""
+	for n := 0; n < 100; n++ {
+		data += fmt.Sprintf("{\"id\":\"%d\"}\n", n)
+	}
+	segments := segmentAll(t, data, 100)
+	if len(segments) < 2 {
+		t.Fatalf("expected multiple segments, got %d", len(segments))
+	}
+	// segments should cover the whole source, ending on newlines
+	offset := int64(0)
+	joined := ""
+	for _, segment := range segments {
+		bytes, err := ioutil.ReadAll(segment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if segment.Start() != offset || bytes[len(bytes)-1] != '\n' {
+			t.Errorf("expected segment %s to begin at %d and end with a newline", segment.Name(), offset)
+		}
+		if segment.End()-segment.Start() < 100 && segment.End() != int64(len(data)) {
+			t.Errorf("expected segment %s to contain at least 100 bytes", segment.Name())
+		}
+		offset = segment.End()
+		joined += string(bytes)
+	}
+	if joined != data {
+		t.Error("expected the segments to contain the source")
+	}
+}
+
+func TestNextSegmentLongLine(t *testing.T) {
+	// a line longer than the split size is never divided
+	data := strings.Repeat("a", 1000) + "\nb\n"
+	segments := segmentAll(t, data, 10)
+	if len(segments) != 2 {
+		t.Fatalf("expected 2 segments, got %d", len(segments))
+	}
+	if segments[0].End() != 1001 {
+		t.Errorf("expected the first segment to end after the long line, got %d", segments[0].End())
+	}
+}
+
+func TestNextSegmentMissingNewline(t *testing.T) {
+	// the last segment ends at the end of the source
+	data := "a\nb\nc"
+	segments := segmentAll(t, data, 3)
+	last := segments[len(segments)-1]
+	if last.End() != int64(len(data)) {
+		t.Errorf("expected the last segment to end at %d, got %d", len(data), last.End())
+	}
+}
+
+func TestSegmentName(t *testing.T) {
+	segment, err := NextSegment(strings.NewReader("a\nb\n"), "data.json", 0, 1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if segment.Name() != "data.json@0-2" || segment.Source() != "data.json" {
+		t.Errorf("unexpected segment name %s of source %s", segment.Name(), segment.Source())
+	}
+}
+
+func TestIsSplittable(t *testing.T) {
+	splittable := map[string]bool{
+		"data.json":         true,
+		"data.csv":          true,
+		"data":              true,
+		"dir.gz/data.json":  true,
+		"data.json.gz":      false,
+		"data.JSON.GZ":      false,
+		"data.bz2":          false,
+		"data.zlib":         false,
+		"data.deflate":      false,
+		"data.zst":          false,
+		"archive/data.zip":  false,
+		"s3://bucket/a.xz":  false,
+		"data.json.snappy":  false,
+		"data.gz.json":      true,
+		"data.json.lz4":     false,
+		"data.json.Z":       false,
+		"data.json.zz":      false,
+		"data.json.gzip":    false,
+		"data.json.partial": true,
+	}
+	for name, expected := range splittable {
+		if IsSplittable(name) != expected {
+			t.Errorf("expected IsSplittable(%q) to be %v", name, expected)
+		}
+	}
+}
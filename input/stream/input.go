@@ -0,0 +1,91 @@
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/unchartedsoftware/deluge/input"
+	"github.com/unchartedsoftware/deluge/util"
+)
+
+const defaultChunkSize = 4 * 1024 * 1024
+
+// Input represents an input type for reading from a stream such as stdin. The
+// stream is split into chunks ending on newline boundaries so that they can be
+// parsed by separate workers. The stream must not be compressed, since a
+// compressed stream cannot be split.
+type Input struct {
+	reader    *bufio.Reader
+	name      string
+	chunkSize int
+	offset    int64
+	numChunks int64
+	done      bool
+}
+
+// NewInput instantiates a new instance of a stream input. Each chunk contains
+// at least chunkSize bytes, extended to the end of the line, and is named by
+// the stream name and its byte offset, ex. `stdin@4194304`. A chunkSize of
+// zero uses the default of 4MB.
+func NewInput(reader io.Reader, name string, chunkSize int) (*Input, error) {
+	if chunkSize < 0 {
+		return nil, fmt.Errorf("Invalid chunk size %d for stream input `%s`", chunkSize, name)
+	}
+	if chunkSize == 0 {
+		chunkSize = defaultChunkSize
+	}
+	return &Input{
+		reader:    bufio.NewReader(reader),
+		name:      name,
+		chunkSize: chunkSize,
+	}, nil
+}
+
+// Next reads the next chunk of the stream and returns the reader.
+func (i *Input) Next() (io.Reader, error) {
+	if i.done {
+		return nil, io.EOF
+	}
+	chunk := make([]byte, i.chunkSize)
+	n, err := io.ReadFull(i.reader, chunk)
+	chunk = chunk[:n]
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// end of stream
+		i.done = true
+	} else if err != nil {
+		return nil, err
+	} else if chunk[n-1] != '\n' {
+		// extend the chunk to the end of the current line
+		line, err := i.reader.ReadBytes('\n')
+		if err == io.EOF {
+			i.done = true
+		} else if err != nil {
+			return nil, err
+		}
+		chunk = append(chunk, line...)
+	}
+	if len(chunk) == 0 {
+		return nil, io.EOF
+	}
+	offset := atomic.AddInt64(&i.offset, int64(len(chunk))) - int64(len(chunk))
+	atomic.AddInt64(&i.numChunks, 1)
+	name := fmt.Sprintf("%s@%d", i.name, offset)
+	return input.NewReader(bytes.NewReader(chunk), name), nil
+}
+
+// Summary returns a string containing summary information. Since the length
+// of a stream is unknown until it has been read, it reports the bytes consumed
+// so far, if any.
+func (i *Input) Summary() string {
+	numChunks := atomic.LoadInt64(&i.numChunks)
+	if numChunks == 0 {
+		return fmt.Sprintf("Input `%s` is a stream of unknown size", i.name)
+	}
+	return fmt.Sprintf("Input `%s` has consumed %d chunks containing %s",
+		i.name,
+		numChunks,
+		util.FormatBytes(atomic.LoadInt64(&i.offset)))
+}
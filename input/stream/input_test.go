@@ -0,0 +1,99 @@
+package stream
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/unchartedsoftware/deluge/input"
+	"github.com/unchartedsoftware/deluge/util"
+)
+
+// readChunks reads every chunk of the input, returning their names and
+// contents.
+func readChunks(t *testing.T, in *Input) ([]string, []string) {
+	t.Helper()
+	var names []string
+	var chunks []string
+	for {
+		reader, err := in.Next()
+		if err == io.EOF {
+			return names, chunks
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		bytes, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, reader.(*input.Reader).Name())
+		chunks = append(chunks, string(bytes))
+	}
+}
+
+func TestInvalidChunkSize(t *testing.T) {
+	_, err := NewInput(strings.NewReader(""), "stdin", -1)
+	if err == nil {
+		t.Error("expected an error for a negative chunk size")
+	}
+}
+
+func TestSummaryBeforeReading(t *testing.T) {
+	in, err := NewInput(strings.NewReader("{}\n"), "stdin", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := in.Summary()
+	if summary != "Input `stdin` is a stream of unknown size" {
+		t.Errorf("expected summary to report an unknown size, got %s", summary)
+	}
+}
+
+func TestReadFromPipe(t *testing.T) {
+	lines := "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n{\"id\":4}\n"
+	// a pipe cannot be seeked or sized, unlike a file
+	reader, writer := io.Pipe()
+	go func() {
+		writer.Write([]byte(lines))
+		writer.Close()
+	}()
+	in, err := NewInput(reader, "pipe", 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, chunks := readChunks(t, in)
+	// each chunk is extended to the end of the line
+	expectedNames := []string{"pipe@0", "pipe@18"}
+	expectedChunks := []string{"{\"id\":1}\n{\"id\":2}\n", "{\"id\":3}\n{\"id\":4}\n"}
+	if strings.Join(names, ",") != strings.Join(expectedNames, ",") {
+		t.Errorf("expected chunks %v, got %v", expectedNames, names)
+	}
+	if strings.Join(chunks, "") != lines {
+		t.Errorf("expected chunks to contain %q, got %q", lines, strings.Join(chunks, ""))
+	}
+	for n, chunk := range chunks {
+		if n < len(expectedChunks) && chunk != expectedChunks[n] {
+			t.Errorf("expected chunk %d to be %q, got %q", n, expectedChunks[n], chunk)
+		}
+	}
+	summary := in.Summary()
+	if summary != "Input `pipe` has consumed 2 chunks containing "+util.FormatBytes(int64(len(lines))) {
+		t.Errorf("unexpected summary %s", summary)
+	}
+}
+
+func TestReadWithoutTrailingNewline(t *testing.T) {
+	in, err := NewInput(strings.NewReader("{\"id\":1}\n{\"id\":2}"), "stdin", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, chunks := readChunks(t, in)
+	if len(chunks) != 2 || chunks[0] != "{\"id\":1}\n" || chunks[1] != "{\"id\":2}" {
+		t.Errorf("unexpected chunks %q", chunks)
+	}
+	if len(names) != 2 || names[1] != "stdin@9" {
+		t.Errorf("unexpected chunk names %v", names)
+	}
+}
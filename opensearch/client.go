@@ -0,0 +1,151 @@
+package opensearch
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/opensearch-project/opensearch-go"
+	"github.com/opensearch-project/opensearch-go/signer"
+
+	"github.com/unchartedsoftware/deluge/elastic/rest"
+)
+
+// ClientOptionFunc is a function that configures the underlying
+// opensearch client. It is used in NewClient.
+type ClientOptionFunc func(*opensearch.Config) error
+
+// SetURL defines the URL endpoints of the OpenSearch nodes.
+func SetURL(urls ...string) ClientOptionFunc {
+	return func(c *opensearch.Config) error {
+		c.Addresses = urls
+		return nil
+	}
+}
+
+// SetBasicAuth can be used to specify the HTTP Basic Auth credentials to
+// use when making HTTP requests to OpenSearch.
+func SetBasicAuth(username string, password string) ClientOptionFunc {
+	return func(c *opensearch.Config) error {
+		c.Username = username
+		c.Password = password
+		return nil
+	}
+}
+
+// SetSigner sets the signer used to sign every request, ex. an AWS SigV4
+// signer for Amazon OpenSearch Service.
+func SetSigner(s signer.Signer) ClientOptionFunc {
+	return func(c *opensearch.Config) error {
+		c.Signer = s
+		return nil
+	}
+}
+
+// SetCACert sets the PEM-encoded certificate authorities to trust. It is only
+// valid when no transport, or an *http.Transport, is specified.
+func SetCACert(cert []byte) ClientOptionFunc {
+	return func(c *opensearch.Config) error {
+		c.CACert = cert
+		return nil
+	}
+}
+
+// SetHTTPClient can be used to specify the http.Client to use when making
+// HTTP requests to OpenSearch. Only the transport of the client is used.
+func SetHTTPClient(client *http.Client) ClientOptionFunc {
+	return func(c *opensearch.Config) error {
+		c.Transport = client.Transport
+		return nil
+	}
+}
+
+// SetTransport sets the HTTP transport to use when making HTTP requests to
+// OpenSearch.
+func SetTransport(transport http.RoundTripper) ClientOptionFunc {
+	return func(c *opensearch.Config) error {
+		c.Transport = transport
+		return nil
+	}
+}
+
+// SetHeader sets a global HTTP header sent with every request.
+func SetHeader(name string, value string) ClientOptionFunc {
+	return func(c *opensearch.Config) error {
+		if c.Header == nil {
+			c.Header = http.Header{}
+		}
+		c.Header.Set(name, value)
+		return nil
+	}
+}
+
+// SetSniff enables or disables discovering the cluster nodes on startup
+// (disabled by default).
+func SetSniff(sniff bool) ClientOptionFunc {
+	return func(c *opensearch.Config) error {
+		c.DiscoverNodesOnStart = sniff
+		return nil
+	}
+}
+
+// SetSnifferInterval sets the interval between two node discoveries. The
+// default is to not periodically discover nodes.
+func SetSnifferInterval(interval time.Duration) ClientOptionFunc {
+	return func(c *opensearch.Config) error {
+		c.DiscoverNodesInterval = interval
+		return nil
+	}
+}
+
+// SetMaxRetries sets the maximum number of retries before giving up when
+// performing a HTTP request to OpenSearch.
+func SetMaxRetries(maxRetries int) ClientOptionFunc {
+	return func(c *opensearch.Config) error {
+		c.MaxRetries = maxRetries
+		return nil
+	}
+}
+
+// SetRetryOnStatus sets the HTTP status codes that are retried. The default
+// is 502, 503 and 504.
+func SetRetryOnStatus(statuses ...int) ClientOptionFunc {
+	return func(c *opensearch.Config) error {
+		c.RetryOnStatus = statuses
+		return nil
+	}
+}
+
+// SetGzip enables or disables gzip compression of request bodies (disabled by
+// default).
+func SetGzip(enabled bool) ClientOptionFunc {
+	return func(c *opensearch.Config) error {
+		c.CompressRequestBody = enabled
+		return nil
+	}
+}
+
+// Client represents an opensearch client compatible with versions 1.x.x and 2.x.x.
+type Client struct {
+	*rest.Client
+}
+
+// NewClient returns a new opensearch client.
+func NewClient(shards uint16, options ...ClientOptionFunc) (*Client, error) {
+	config := opensearch.Config{}
+	for _, option := range options {
+		if err := option(&config); err != nil {
+			return nil, err
+		}
+	}
+	client, err := opensearch.NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+	c, err := rest.NewClient(client, shards)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		Client: c,
+	}, nil
+}
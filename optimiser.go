@@ -3,7 +3,6 @@ package deluge
 import (
 	"time"
 
-	"github.com/unchartedsoftware/deluge/progress"
 	"github.com/unchartedsoftware/plog"
 )
 
@@ -45,9 +44,9 @@ func NewBulkSize(ingestor *Ingestor) *BulkSize {
 // Score will provide a score for the bulk size by getting the docs / second
 // processed over a defined period.
 func (bs *BulkSize) Score() float64 {
-	startDocCount := progress.GetDocCount()
+	startDocCount := bs.Ingestor.progress.GetDocCount()
 	time.Sleep(time.Second * scoringDuration)
-	endDocCount := progress.GetDocCount()
+	endDocCount := bs.Ingestor.progress.GetDocCount()
 
 	return float64(endDocCount-startDocCount) / scoringDuration
 }
@@ -1,5 +1,12 @@
 package deluge
 
+import (
+	"time"
+
+	"github.com/unchartedsoftware/deluge/checkpoint"
+	"github.com/unchartedsoftware/deluge/deadletter"
+)
+
 // IngestorOptionFunc is a function that configures an Ingestor. It is used in
 // NewIngestor.
 type IngestorOptionFunc func(*Ingestor) error
@@ -75,7 +82,8 @@ func ClearExistingIndex(clearExisting bool) IngestorOptionFunc {
 }
 
 // SetCompression sets the compression type for the input files. Supports:
-// "bzip2", "flate", "gzip", "zlib".
+// "bzip2", "flate", "gzip", "zlib" and "auto", which detects the compression
+// of each source from its file extension or, failing that, its magic bytes.
 func SetCompression(compression string) IngestorOptionFunc {
 	return func(i *Ingestor) error {
 		i.compression = compression
@@ -144,3 +152,191 @@ func SetBlockWrite(blockWrite bool) IngestorOptionFunc {
 		return nil
 	}
 }
+
+// SetCheckpointStore sets the store used to record which sources, and the
+// offsets within them, have been acknowledged by elasticsearch.
+func SetCheckpointStore(store checkpoint.Store) IngestorOptionFunc {
+	return func(i *Ingestor) error {
+		i.checkpointStore = store
+		return nil
+	}
+}
+
+// ResumeFromCheckpoint sets whether or not to resume from the checkpoint
+// store, skipping any data that was already acknowledged. An existing index
+// will not be cleared when resuming from a non-empty checkpoint.
+func ResumeFromCheckpoint(resume bool) IngestorOptionFunc {
+	return func(i *Ingestor) error {
+		i.resume = resume
+		return nil
+	}
+}
+
+// SetAlias enables alias mode. Each ingest is written into a fresh index named
+// after the index set with SetIndex() followed by a UTC timestamp. Only once
+// the ingest succeeds is the alias atomically pointed to the new index.
+func SetAlias(alias string) IngestorOptionFunc {
+	return func(i *Ingestor) error {
+		i.alias = alias
+		return nil
+	}
+}
+
+// SetRetainIndices sets the number of previous timestamped indices to retain
+// after the alias has been swapped in alias mode. Older indices are deleted.
+// A negative value retains all previous indices.
+func SetRetainIndices(numIndices int) IngestorOptionFunc {
+	return func(i *Ingestor) error {
+		i.retainIndices = numIndices
+		return nil
+	}
+}
+
+// SetDeadLetter sets the sink to write documents to that failed to parse or
+// were rejected by elasticsearch.
+func SetDeadLetter(sink deadletter.Sink) IngestorOptionFunc {
+	return func(i *Ingestor) error {
+		i.deadLetter = sink
+		return nil
+	}
+}
+
+// SetDryRun sets whether or not to perform a dry run. A dry run reads, parses
+// and builds the bulk payloads for all input, but never modifies any index or
+// sends any documents to elasticsearch. Like any other ingest, a dry run is
+// aborted once the error threshold is surpassed, and its summary reports the
+// errors encountered up to that point.
+func SetDryRun(dryRun bool) IngestorOptionFunc {
+	return func(i *Ingestor) error {
+		i.dryRun = dryRun
+		return nil
+	}
+}
+
+// SetIndexTemplate sets the index template to install before the ingest, so
+// that indices created by the cluster receive the same mapping and settings.
+// If the template has no mapping, the mapping of the document is used.
+func SetIndexTemplate(template *IndexTemplate) IngestorOptionFunc {
+	return func(i *Ingestor) error {
+		i.indexTemplate = template
+		return nil
+	}
+}
+
+// AddComponentTemplate adds a component template to install before the index
+// template. The template is the JSON encoded request body.
+func AddComponentTemplate(name string, template string) IngestorOptionFunc {
+	return func(i *Ingestor) error {
+		i.componentTemplates = append(i.componentTemplates, &namedBody{
+			name: name,
+			body: template,
+		})
+		return nil
+	}
+}
+
+// SetLifecyclePolicy sets the index lifecycle policy to install before the
+// ingest. The policy is the JSON encoded request body. Unless the settings of
+// the index template already declare a policy, the template is assigned this
+// policy.
+func SetLifecyclePolicy(name string, policy string) IngestorOptionFunc {
+	return func(i *Ingestor) error {
+		i.lifecyclePolicy = &namedBody{
+			name: name,
+			body: policy,
+		}
+		return nil
+	}
+}
+
+// SetIndexSettings sets the JSON encoded settings merged into the body of
+// created indices. The number of replicas is always set with
+// SetNumReplicas().
+func SetIndexSettings(settings string) IngestorOptionFunc {
+	return func(i *Ingestor) error {
+		i.indexSettings = settings
+		return nil
+	}
+}
+
+// SetTuneSettings sets whether or not to disable refreshes, make translog
+// durability asynchronous and limit merge threads for the duration of the
+// ingest. Once the ingest ends, successfully or not, the settings are restored
+// to the values set with SetIndexSettings(), or to their defaults.
+func SetTuneSettings(tune bool) IngestorOptionFunc {
+	return func(i *Ingestor) error {
+		i.tuneSettings = tune
+		return nil
+	}
+}
+
+// SetRefresh sets whether or not to refresh the index once the ingest
+// succeeds, making all ingested documents visible to search before Ingest
+// returns.
+func SetRefresh(refresh bool) IngestorOptionFunc {
+	return func(i *Ingestor) error {
+		i.refresh = refresh
+		return nil
+	}
+}
+
+// SetForceMerge sets the maximum number of segments the index is force merged
+// down to once the ingest succeeds. The merge runs before replicas are
+// enabled, so that replicas copy the merged segments. A value of 0 disables
+// the force merge.
+func SetForceMerge(maxNumSegments int) IngestorOptionFunc {
+	return func(i *Ingestor) error {
+		i.forceMerge = maxNumSegments
+		return nil
+	}
+}
+
+// SetWaitForGreen sets how long to wait for the health of the index to turn
+// green once replicas are enabled. Ingest returns an error if the index is not
+// green before the timeout expires. A value of 0 disables the wait.
+func SetWaitForGreen(timeout time.Duration) IngestorOptionFunc {
+	return func(i *Ingestor) error {
+		i.waitForGreen = timeout
+		return nil
+	}
+}
+
+// SetSnapshot sets the snapshot repository to archive the index to once the
+// ingest succeeds and replicas are enabled. If the repository does not exist,
+// a shared file system repository is registered at the provided location,
+// which must be listed in the path.repo setting of every node. Unless a name
+// is provided, the snapshot is named after the index followed by a UTC
+// timestamp. Ingest waits for the snapshot to complete, and returns an error
+// unless it succeeded.
+func SetSnapshot(repository string, location string, name string) IngestorOptionFunc {
+	return func(i *Ingestor) error {
+		i.snapshotRepository = repository
+		i.snapshotLocation = location
+		i.snapshotName = name
+		return nil
+	}
+}
+
+// SetVerify sets whether or not to verify the document count of every index
+// once the ingest succeeds. Each index is refreshed and its count compared to
+// the unique ids of the documents acknowledged by elasticsearch, reporting the
+// documents collapsed by duplicate ids separately from missing documents. The
+// ids of every acknowledged document are kept in memory for the duration of
+// the ingest.
+func SetVerify(verify bool) IngestorOptionFunc {
+	return func(i *Ingestor) error {
+		i.verify = verify
+		return nil
+	}
+}
+
+// SetVerifyTolerance sets the ratio of missing documents to expected documents
+// tolerated by the verification before the ingest fails. Documents in the
+// index that were not submitted by the ingest do not count towards the
+// tolerance. The default is 0.
+func SetVerifyTolerance(tolerance float64) IngestorOptionFunc {
+	return func(i *Ingestor) error {
+		i.verifyTolerance = tolerance
+		return nil
+	}
+}
@@ -1,6 +1,7 @@
 package pool
 
 import (
+	"context"
 	"io"
 )
 
@@ -54,9 +55,15 @@ func (p *Pool) close(closingErr error) error {
 	// workers will currently be blocked trying to send a ready/error message
 	// to the pool. We need to absorb those messages now so that they will be
 	// able to process the kill signals.
+	drained := make(chan struct{})
 	go func() {
+		defer close(drained)
 		for i := 0; i < p.Size; i++ {
-			err := <-p.ErrChan
+			err, ok := <-p.ErrChan
+			if !ok {
+				// every outstanding message has been absorbed
+				return
+			}
 			// still need to check for errors
 			if err != nil && closingErr == nil {
 				closingErr = err
@@ -71,6 +78,8 @@ func (p *Pool) close(closingErr error) error {
 	close(p.WorkChan)
 	close(p.KillChan)
 	close(p.ErrChan)
+	// wait until the absorbed messages have been checked for errors
+	<-drained
 	// return the first error
 	return closingErr
 }
@@ -85,15 +94,29 @@ func (p *Pool) open(worker Worker) {
 
 // Execute launches a batch of ingest workers with the provided ingest information.
 func (p *Pool) Execute(worker Worker, work Work) error {
+	return p.ExecuteContext(context.Background(), worker, work)
+}
+
+// ExecuteContext launches a batch of ingest workers with the provided ingest
+// information. If the context is cancelled no further work is requested and
+// the pool is closed, returning the context error.
+func (p *Pool) ExecuteContext(ctx context.Context, worker Worker, work Work) error {
 	// open the pool and dispatch the workers
 	p.open(worker)
 	// process all files by spreading them to free workers, this blocks until
 	// a worker is available, or exits if there is an error
 	for {
-		err := <-p.ErrChan
-		if err != nil {
-			// error has occurred, close the pool and return error
-			return p.close(err)
+		select {
+		case err := <-p.ErrChan:
+			if err != nil {
+				// error has occurred, close the pool and return error
+				return p.close(err)
+			}
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			// cancelled, close the pool and return the context error
+			return p.close(ctx.Err())
 		}
 		next, err := work.Next()
 		if err == io.EOF {
@@ -15,112 +15,125 @@ const (
 	clearLine = "\x1b[2K"
 )
 
-var (
+// Progress tracks and prints the progress of an ingest.
+type Progress struct {
 	startTime    time.Time
 	endTime      time.Time
 	currentBytes int64
 	currentDocs  int64
-	bytesPerSec  = int64(1)
-	docsPerSec   = int64(1)
-	mutex        = sync.Mutex{}
+	bytesPerSec  int64
+	docsPerSec   int64
+	mutex        *sync.Mutex
 	endChan      chan bool
-	wg           = sync.WaitGroup{}
-)
+	wg           *sync.WaitGroup
+}
+
+// New instantiates a new progress tracker.
+func New() *Progress {
+	return &Progress{
+		bytesPerSec: 1,
+		docsPerSec:  1,
+		mutex:       &sync.Mutex{},
+		wg:          &sync.WaitGroup{},
+	}
+}
 
-func duration() time.Duration {
+func (p *Progress) duration() time.Duration {
 	now := time.Now().Round(time.Second)
-	return now.Sub(startTime)
+	return now.Sub(p.startTime)
 }
 
-func print() {
+func (p *Progress) print() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
 	// print the current progress
 	fmt.Printf("%s\rIngested %s (%d docs) at a rate of %sps (%d docs / sec), current duration: %v",
 		clearLine,
-		util.FormatBytes(currentBytes),
-		currentDocs,
-		util.FormatBytes(bytesPerSec),
-		docsPerSec,
-		duration())
+		util.FormatBytes(p.currentBytes),
+		p.currentDocs,
+		util.FormatBytes(p.bytesPerSec),
+		p.docsPerSec,
+		p.duration())
 }
 
-func tick() {
-	wg.Add(1)
+func (p *Progress) tick() {
 	ticker := time.NewTicker(time.Second)
 	for {
 		select {
-		case <-endChan:
+		case <-p.endChan:
 			// stop the ticker
 			ticker.Stop()
 			// print last progress
-			print()
-			wg.Done()
+			p.print()
+			p.wg.Done()
 			return
 
 		case <-ticker.C:
 			// print the current progress
-			print()
+			p.print()
 		}
 	}
 }
 
-// StartProgress sets the internal epoch and the total bytes to track.
-func StartProgress() {
-	startTime = time.Now().Round(time.Second)
-	currentBytes = 0
-	currentDocs = 0
-	endChan = make(chan bool)
-	go tick()
+// Start sets the internal epoch and the total bytes to track.
+func (p *Progress) Start() {
+	p.startTime = time.Now().Round(time.Second)
+	p.currentBytes = 0
+	p.currentDocs = 0
+	p.endChan = make(chan bool)
+	p.wg.Add(1)
+	go p.tick()
 }
 
-// EndProgress sets the end time.
-func EndProgress() {
-	endTime = time.Now().Round(time.Second)
-	endChan <- true
-	wg.Wait()
-	close(endChan)
+// End sets the end time.
+func (p *Progress) End() {
+	p.endTime = time.Now().Round(time.Second)
+	p.endChan <- true
+	p.wg.Wait()
+	close(p.endChan)
 }
 
 // GetDocCount returns the current doc count.
-func GetDocCount() int64 {
-	mutex.Lock()
-	docs := currentDocs
-	mutex.Unlock()
+func (p *Progress) GetDocCount() int64 {
+	p.mutex.Lock()
+	docs := p.currentDocs
+	p.mutex.Unlock()
 
 	return docs
 }
 
-// UpdateProgress will update and print a human readable progress message for
+// Update will update and print a human readable progress message for
 // a given task.
-func UpdateProgress(bytes, docs int64) {
-	mutex.Lock()
+func (p *Progress) Update(bytes, docs int64) {
+	p.mutex.Lock()
 	// increment the totals
-	currentBytes += bytes
-	currentDocs += docs
+	p.currentBytes += bytes
+	p.currentDocs += docs
 
 	// set the current ingest speed
-	elapsedSec := int64(duration().Seconds())
+	elapsedSec := int64(p.duration().Seconds())
 	if elapsedSec > 0 {
-		bytesPerSec = currentBytes / elapsedSec
-		docsPerSec = currentDocs / elapsedSec
+		p.bytesPerSec = p.currentBytes / elapsedSec
+		p.docsPerSec = p.currentDocs / elapsedSec
 	} else {
-		bytesPerSec = currentBytes
-		docsPerSec = currentDocs
+		p.bytesPerSec = p.currentBytes
+		p.docsPerSec = p.currentDocs
 	}
 
-	mutex.Unlock()
+	p.mutex.Unlock()
 	runtime.Gosched()
 }
 
 // PrintFailure prints the total duration of the processed task.
-func PrintFailure() {
-	elapsed := endTime.Sub(startTime)
+func (p *Progress) PrintFailure() {
+	elapsed := p.endTime.Sub(p.startTime)
 	fmt.Print("\n")
 	log.Infof("Ingestion failed after %v", elapsed)
 }
 
 // PrintSuccess prints the total duration of the processed task.
-func PrintSuccess() {
-	elapsed := endTime.Sub(startTime)
+func (p *Progress) PrintSuccess() {
+	elapsed := p.endTime.Sub(p.startTime)
 	fmt.Print("\n")
 	log.Infof("Ingestion completed in %v", elapsed)
 }
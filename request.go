@@ -1,10 +1,27 @@
 package deluge
 
+import (
+	"context"
+)
+
 // BulkRequest represents a bulked elasticsearch request.
 type BulkRequest interface {
 	Add(string, string, interface{})
+	AddItem(*BulkItem)
 	EstimatedSizeInBytes() int64
 	Size() int
-	Send() (uint64, error)
+	Send(context.Context) (uint64, error)
 	Took() uint64
+	Failed() []*BulkItemError
+}
+
+// BulkItem represents a single document operation within a bulk request.
+// An empty Index targets the index of the bulk request.
+type BulkItem struct {
+	Operation Operation
+	Index     string
+	Type      string
+	ID        string
+	Source    interface{}
+	Metadata  *Metadata
 }
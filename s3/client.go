@@ -0,0 +1,213 @@
+package s3
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	s3input "github.com/unchartedsoftware/deluge/input/s3"
+)
+
+const defaultRegion = "us-east-1"
+
+// ClientOptionFunc is a function that configures an S3 client.
+type ClientOptionFunc func(*Client) error
+
+// SetURL sets the endpoint URL of the S3-compatible service, ex.
+// "http://localhost:9000". The default is the AWS endpoint of the region.
+func SetURL(url string) ClientOptionFunc {
+	return func(c *Client) error {
+		if url != "" {
+			c.endpoint = strings.TrimSuffix(url, "/")
+		}
+		return nil
+	}
+}
+
+// SetRegion sets the region used to sign requests. The default is us-east-1.
+func SetRegion(region string) ClientOptionFunc {
+	return func(c *Client) error {
+		if region != "" {
+			c.region = region
+		}
+		return nil
+	}
+}
+
+// SetCredentials sets the credentials used to sign requests. The session
+// token is only required for temporary credentials. Requests are sent
+// anonymously if no credentials are set.
+func SetCredentials(accessKeyID string, secretAccessKey string, sessionToken string) ClientOptionFunc {
+	return func(c *Client) error {
+		c.accessKeyID = accessKeyID
+		c.secretAccessKey = secretAccessKey
+		c.sessionToken = sessionToken
+		return nil
+	}
+}
+
+// SetHTTPClient sets the http.Client used to send requests.
+func SetHTTPClient(client *http.Client) ClientOptionFunc {
+	return func(c *Client) error {
+		if client != nil {
+			c.client = client
+		}
+		return nil
+	}
+}
+
+// Client represents an S3 client. Buckets are addressed with path-style URLs
+// and requests are signed with Signature Version 4.
+type Client struct {
+	endpoint        string
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	client          *http.Client
+}
+
+// NewClient instantiates and returns a new S3 client.
+func NewClient(options ...ClientOptionFunc) (*Client, error) {
+	// instantiate client
+	client := &Client{
+		region: defaultRegion,
+		client: http.DefaultClient,
+	}
+	// run the options through it
+	for _, option := range options {
+		if err := option(client); err != nil {
+			return nil, err
+		}
+	}
+	if client.endpoint == "" {
+		client.endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", client.region)
+	}
+	if _, err := url.Parse(client.endpoint); err != nil {
+		return nil, fmt.Errorf("Invalid S3 endpoint `%s`: %v", client.endpoint, err)
+	}
+	return client, nil
+}
+
+type errorResponse struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+type listResponse struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// do sends a signed request for the object key within the bucket. Responses
+// with an error status code are closed and returned as errors.
+func (c *Client) do(method string, bucket string, key string, query url.Values) (*http.Response, error) {
+	u, err := url.Parse(c.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	// send the path exactly as it is signed
+	u.RawPath = encodeURI(u.Path, true)
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.sign(req, time.Now())
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < http.StatusMultipleChoices {
+		return res, nil
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound && method == http.MethodHead {
+		// HEAD responses do not have a body
+		return nil, &os.PathError{Op: "stat", Path: bucket + "/" + key, Err: os.ErrNotExist}
+	}
+	e := &errorResponse{}
+	body, _ := ioutil.ReadAll(res.Body)
+	if xml.Unmarshal(body, e) != nil || e.Code == "" {
+		return nil, fmt.Errorf("s3: Error %d (%s)", res.StatusCode, http.StatusText(res.StatusCode))
+	}
+	if e.Code == "NoSuchKey" {
+		return nil, &os.PathError{Op: "open", Path: bucket + "/" + key, Err: os.ErrNotExist}
+	}
+	return nil, fmt.Errorf("s3: Error %d (%s): %s [code=%s]", res.StatusCode, http.StatusText(res.StatusCode), e.Message, e.Code)
+}
+
+// List returns every object within the bucket whose key begins with the
+// prefix, sorted by key.
+func (c *Client) List(bucket string, prefix string) ([]*s3input.Object, error) {
+	var objects []*s3input.Object
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		res, err := c.do(http.MethodGet, bucket, "", query)
+		if err != nil {
+			return nil, fmt.Errorf("Error occurred while listing objects: %v", err)
+		}
+		list := &listResponse{}
+		err = xml.NewDecoder(res.Body).Decode(list)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error occurred while listing objects: %v", err)
+		}
+		for _, content := range list.Contents {
+			objects = append(objects, s3input.NewObject(content.Key, content.Size, content.LastModified))
+		}
+		if !list.IsTruncated || list.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = list.NextContinuationToken
+	}
+}
+
+// Stat returns the object with the provided key. An error satisfying
+// os.IsNotExist is returned if the object does not exist.
+func (c *Client) Stat(bucket string, key string) (*s3input.Object, error) {
+	res, err := c.do(http.MethodHead, bucket, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+	size, err := strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Error occurred while parsing size of object `%s`: %v", key, err)
+	}
+	modTime, _ := http.ParseTime(res.Header.Get("Last-Modified"))
+	return s3input.NewObject(key, size, modTime), nil
+}
+
+// Open returns an io.ReadCloser streaming the contents of the object.
+func (c *Client) Open(bucket string, key string) (io.ReadCloser, error) {
+	res, err := c.do(http.MethodGet, bucket, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
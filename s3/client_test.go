@@ -0,0 +1,240 @@
+package s3
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/unchartedsoftware/deluge/input"
+	s3input "github.com/unchartedsoftware/deluge/input/s3"
+	"github.com/unchartedsoftware/deluge/s3/s3test"
+)
+
+const (
+	testBucket          = "data"
+	testAccessKeyID     = "AKIDEXAMPLE"
+	testSecretAccessKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+)
+
+func newTestClient(t *testing.T, options ...s3test.OptionFunc) (*s3test.Server, *Client) {
+	server := s3test.NewServer(append([]s3test.OptionFunc{
+		s3test.SetCredentials(testAccessKeyID, testSecretAccessKey),
+	}, options...)...)
+	client, err := NewClient(
+		SetURL(server.URL),
+		SetRegion("us-west-2"),
+		SetCredentials(testAccessKeyID, testSecretAccessKey, ""))
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return server, client
+}
+
+func readAll(t *testing.T, in *s3input.Input) map[string]string {
+	contents := make(map[string]string)
+	for {
+		reader, err := in.Next()
+		if err == io.EOF {
+			return contents
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		named := reader.(*input.Reader)
+		named.Close()
+		contents[named.Name()] = string(data)
+	}
+}
+
+func TestStatAndOpen(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	key := "2019/01 report (final)+draft~.json"
+	server.PutObject(testBucket, key, []byte(`{"id":"0"}`))
+	object, err := client.Stat(testBucket, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if object.Key() != key || object.Name() != "01 report (final)+draft~.json" || object.Size() != 10 {
+		t.Errorf("unexpected object %s of size %d", object.Key(), object.Size())
+	}
+	if object.ModTime().IsZero() {
+		t.Errorf("expected object modification time to be set")
+	}
+	reader, err := client.Open(testBucket, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"id":"0"}` {
+		t.Errorf("unexpected object contents %s", data)
+	}
+	_, err = client.Stat(testBucket, "missing")
+	if !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+	_, err = client.Open(testBucket, "missing")
+	if !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
+
+func TestListPagination(t *testing.T) {
+	server, client := newTestClient(t, s3test.SetMaxKeys(3))
+	defer server.Close()
+	for n := 0; n < 10; n++ {
+		server.PutObject(testBucket, fmt.Sprintf("logs/%02d.json", n), []byte("{}"))
+	}
+	server.PutObject(testBucket, "other/00.json", []byte("{}"))
+	objects, err := client.List(testBucket, "logs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 10 {
+		t.Fatalf("expected 10 objects, got %d", len(objects))
+	}
+	for n, object := range objects {
+		if object.Key() != fmt.Sprintf("logs/%02d.json", n) {
+			t.Errorf("unexpected object key %s at position %d", object.Key(), n)
+		}
+	}
+	numLists := 0
+	for _, req := range server.Requests() {
+		if req == "GET /"+testBucket {
+			numLists++
+		}
+	}
+	if numLists != 4 {
+		t.Errorf("expected 4 list requests, got %d", numLists)
+	}
+}
+
+func TestSignatureMismatch(t *testing.T) {
+	server := s3test.NewServer(s3test.SetCredentials(testAccessKeyID, testSecretAccessKey))
+	defer server.Close()
+	server.PutObject(testBucket, "0.json", []byte("{}"))
+	client, err := NewClient(
+		SetURL(server.URL),
+		SetCredentials(testAccessKeyID, "invalid", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.List(testBucket, "")
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("expected signature mismatch, got %v", err)
+	}
+	anonymous, err := NewClient(SetURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = anonymous.Open(testBucket, "0.json")
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("expected access denied, got %v", err)
+	}
+}
+
+func TestInput(t *testing.T) {
+	server, client := newTestClient(t, s3test.SetMaxKeys(2))
+	defer server.Close()
+	server.PutObject(testBucket, "ingest/a.json", []byte("a"))
+	server.PutObject(testBucket, "ingest/b.json", []byte("bb"))
+	server.PutObject(testBucket, "ingest/empty.json", nil)
+	server.PutObject(testBucket, "ingest/skip.json", []byte("skip"))
+	server.PutObject(testBucket, "ingest/nested/c.json", []byte("ccc"))
+	server.PutObject(testBucket, "ingest/tmp/d.json", []byte("dddd"))
+	server.PutObject(testBucket, "ingestion/e.json", []byte("eeeee"))
+	server.PutObject(testBucket, "single.json", []byte("ffffff"))
+	in, err := s3input.NewInput(client, []string{
+		"s3://data/ingest",
+		"data/single.json",
+	}, []string{"skip.json", "tmp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := in.Summary()
+	if !strings.Contains(summary, "contains 4 objects containing 12") {
+		t.Errorf("unexpected summary %s", summary)
+	}
+	contents := readAll(t, in)
+	expected := map[string]string{
+		"s3://data/ingest/a.json":        "a",
+		"s3://data/ingest/b.json":        "bb",
+		"s3://data/ingest/nested/c.json": "ccc",
+		"s3://data/single.json":          "ffffff",
+	}
+	if len(contents) != len(expected) {
+		t.Errorf("expected %d sources, got %v", len(expected), contents)
+	}
+	for name, data := range expected {
+		if contents[name] != data {
+			t.Errorf("expected source %s to contain %q, got %q", name, data, contents[name])
+		}
+	}
+}
+
+func TestInputOptions(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	server.PutObject(testBucket, "ingest/part-0.json.gz", []byte("a"))
+	server.PutObject(testBucket, "ingest/part-1.json.gz", []byte("bbbbbbbbbb"))
+	server.PutObject(testBucket, "ingest/_SUCCESS", nil)
+	server.PutObject(testBucket, "ingest/a/part-2.json.gz", []byte("c"))
+	server.PutObject(testBucket, "ingest/a/part-3.json.gz.tmp", []byte("d"))
+	server.PutObject(testBucket, "ingest/staging/part-4.json.gz", []byte("e"))
+	server.PutObject(testBucket, "single.json", []byte("f"))
+	in, err := s3input.NewInput(client, []string{
+		"s3://data/ingest",
+		"data/single.json",
+	}, nil,
+		input.SetIncludes("**/part-*.json.gz"),
+		input.SetExcludeGlobs("staging"),
+		input.SetExcludeRegexps(`\.tmp$`),
+		input.SetMaxSize(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := readAll(t, in)
+	expected := map[string]string{
+		"s3://data/ingest/part-0.json.gz":   "a",
+		"s3://data/ingest/a/part-2.json.gz": "c",
+	}
+	if len(contents) != len(expected) {
+		t.Errorf("expected %d sources, got %v", len(expected), contents)
+	}
+	for name, data := range expected {
+		if contents[name] != data {
+			t.Errorf("expected source %s to contain %q, got %q", name, data, contents[name])
+		}
+	}
+	_, err = s3input.NewInput(client, []string{"s3://data/ingest"}, nil,
+		input.SetExcludeRegexps("("))
+	if err == nil {
+		t.Errorf("expected invalid expression error")
+	}
+}
+
+func TestInputMissingPath(t *testing.T) {
+	server, client := newTestClient(t)
+	defer server.Close()
+	server.PutObject(testBucket, "ingest/a.json", []byte("a"))
+	_, err := s3input.NewInput(client, []string{"s3://data/missing"}, nil)
+	if !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+	_, err = s3input.NewInput(client, []string{"s3://missing/ingest"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "NoSuchBucket") {
+		t.Errorf("expected no such bucket error, got %v", err)
+	}
+}
@@ -0,0 +1,297 @@
+// Package s3test provides an in-memory fake S3-compatible object storage
+// server for testing inputs without a running service.
+package s3test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultMaxKeys = 1000
+
+// OptionFunc is a function that configures a Server. It is used in NewServer.
+type OptionFunc func(*Server)
+
+// SetCredentials sets the credentials every request must be signed with. By
+// default requests are not authenticated.
+func SetCredentials(accessKeyID string, secretAccessKey string) OptionFunc {
+	return func(s *Server) {
+		s.accessKeyID = accessKeyID
+		s.secretAccessKey = secretAccessKey
+	}
+}
+
+// SetMaxKeys sets the maximum number of keys returned by a single list
+// request. The default is 1000.
+func SetMaxKeys(maxKeys int) OptionFunc {
+	return func(s *Server) {
+		s.maxKeys = maxKeys
+	}
+}
+
+type object struct {
+	data    []byte
+	modTime time.Time
+}
+
+// Server represents an in-memory S3-compatible server. It emulates the
+// path-style list, head and get object endpoints, and verifies Signature
+// Version 4 authorization headers.
+type Server struct {
+	*httptest.Server
+	accessKeyID     string
+	secretAccessKey string
+	maxKeys         int
+	mutex           sync.Mutex
+	buckets         map[string]map[string]*object
+	requests        []string
+}
+
+// NewServer starts and returns a new server. The caller should call Close
+// when finished, to shut it down.
+func NewServer(options ...OptionFunc) *Server {
+	s := &Server{
+		maxKeys: defaultMaxKeys,
+		buckets: make(map[string]map[string]*object),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// PutObject stores the object in the bucket, creating the bucket if it does
+// not exist.
+func (s *Server) PutObject(bucket string, key string, data []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	objects, ok := s.buckets[bucket]
+	if !ok {
+		objects = make(map[string]*object)
+		s.buckets[bucket] = objects
+	}
+	objects[key] = &object{
+		data:    append([]byte(nil), data...),
+		modTime: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+// Requests returns the method and path of every request received by the
+// server, ex. "GET /bucket/key".
+func (s *Server) Requests() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	requests := make([]string, len(s.requests))
+	copy(requests, s.requests)
+	return requests
+}
+
+type errorBody struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource"`
+}
+
+func writeXML(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code string, message string) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+	writeXML(w, status, &errorBody{
+		Code:     code,
+		Message:  message,
+		Resource: r.URL.Path,
+	})
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	if s.accessKeyID != "" {
+		if code, message := s.authorize(r); code != "" {
+			writeError(w, r, http.StatusForbidden, code, message)
+			return
+		}
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	objects, ok := s.buckets[parts[0]]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	if len(parts) == 1 || parts[1] == "" {
+		if r.Method != http.MethodGet {
+			writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource")
+			return
+		}
+		s.handleList(w, r, parts[0], objects)
+		return
+	}
+	obj, ok := objects[parts[1]]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return
+	}
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		w.Header().Set("Last-Modified", obj.modTime.Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(obj.data)
+		}
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource")
+	}
+}
+
+type listContent struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	Size         int    `xml:"Size"`
+}
+
+type listBody struct {
+	XMLName               xml.Name      `xml:"ListBucketResult"`
+	Name                  string        `xml:"Name"`
+	Prefix                string        `xml:"Prefix"`
+	KeyCount              int           `xml:"KeyCount"`
+	MaxKeys               int           `xml:"MaxKeys"`
+	IsTruncated           bool          `xml:"IsTruncated"`
+	ContinuationToken     string        `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string        `xml:"NextContinuationToken,omitempty"`
+	Contents              []listContent `xml:"Contents"`
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request, bucket string, objects map[string]*object) {
+	query := r.URL.Query()
+	if query.Get("list-type") != "2" {
+		writeError(w, r, http.StatusNotImplemented, "NotImplemented", "Only ListObjectsV2 is supported")
+		return
+	}
+	prefix := query.Get("prefix")
+	// continuation tokens are the last key of the previous page
+	token := query.Get("continuation-token")
+	var keys []string
+	for key := range objects {
+		if strings.HasPrefix(key, prefix) && key > token {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	body := &listBody{
+		Name:              bucket,
+		Prefix:            prefix,
+		MaxKeys:           s.maxKeys,
+		ContinuationToken: token,
+	}
+	if len(keys) > s.maxKeys {
+		keys = keys[:s.maxKeys]
+		body.IsTruncated = true
+		body.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, key := range keys {
+		obj := objects[key]
+		body.Contents = append(body.Contents, listContent{
+			Key:          key,
+			LastModified: obj.modTime.Format("2006-01-02T15:04:05.000Z"),
+			Size:         len(obj.data),
+		})
+	}
+	body.KeyCount = len(body.Contents)
+	writeXML(w, http.StatusOK, body)
+}
+
+// authorize verifies the Signature Version 4 authorization header of the
+// request, returning an error code and message if it is invalid.
+func (s *Server) authorize(r *http.Request) (string, string) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+		return "AccessDenied", "Access Denied"
+	}
+	fields := make(map[string]string)
+	for _, field := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) == 2 {
+			fields[kv[0]] = kv[1]
+		}
+	}
+	credential := strings.Split(fields["Credential"], "/")
+	if len(credential) != 5 || credential[3] != "s3" || credential[4] != "aws4_request" {
+		return "AuthorizationHeaderMalformed", "The authorization header is malformed"
+	}
+	if credential[0] != s.accessKeyID {
+		return "InvalidAccessKeyId", "The AWS Access Key Id you provided does not exist in our records."
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	if !strings.HasPrefix(amzDate, credential[1]) {
+		return "AuthorizationHeaderMalformed", "The authorization header is malformed"
+	}
+	// rebuild the canonical request from the received request
+	var headers []string
+	for _, name := range strings.Split(fields["SignedHeaders"], ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		headers = append(headers, name+":"+strings.TrimSpace(value)+"\n")
+	}
+	var params []string
+	for key, values := range r.URL.Query() {
+		for _, value := range values {
+			params = append(params, escape(key, false)+"="+escape(value, false))
+		}
+	}
+	sort.Strings(params)
+	canonical := strings.Join([]string{
+		r.Method,
+		escape(r.URL.Path, true),
+		strings.Join(params, "&"),
+		strings.Join(headers, ""),
+		fields["SignedHeaders"],
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + strings.Join(credential[1:], "/") + "\n" + hex.EncodeToString(hash[:])
+	key := []byte("AWS4" + s.secretAccessKey)
+	for _, part := range append(credential[1:], toSign) {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	if hex.EncodeToString(key) != fields["Signature"] {
+		return "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided."
+	}
+	return "", ""
+}
+
+// escape encodes the string as required by Signature Version 4.
+func escape(str string, isPath bool) string {
+	escaped := url.QueryEscape(str)
+	escaped = strings.Replace(escaped, "+", "%20", -1)
+	escaped = strings.Replace(escaped, "%7E", "~", -1)
+	if isPath {
+		escaped = strings.Replace(escaped, "%2F", "/", -1)
+	}
+	return escaped
+}
@@ -0,0 +1,70 @@
+package deluge
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// tunedSettings are the index settings applied for the duration of an ingest
+// to speed up bulk loading.
+var tunedSettings = map[string]interface{}{
+	"index.refresh_interval":                 "-1",
+	"index.translog.durability":              "async",
+	"index.merge.scheduler.max_thread_count": 1,
+}
+
+// FlattenSettings parses the JSON encoded index settings into a map of flat
+// setting keys, each prefixed with "index.". Settings may optionally be
+// wrapped in a "settings" object. An empty string returns an empty map.
+func FlattenSettings(settings string) (map[string]interface{}, error) {
+	flat := make(map[string]interface{})
+	if settings == "" {
+		return flat, nil
+	}
+	parsed := make(map[string]interface{})
+	err := json.Unmarshal([]byte(settings), &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("Error occurred while parsing index settings: %v", err)
+	}
+	if inner, ok := parsed["settings"].(map[string]interface{}); ok && len(parsed) == 1 {
+		parsed = inner
+	}
+	flattenSettings("", parsed, flat)
+	return flat, nil
+}
+
+func flattenSettings(prefix string, settings map[string]interface{}, flat map[string]interface{}) {
+	for key, value := range settings {
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenSettings(prefix+key+".", nested, flat)
+			continue
+		}
+		key = prefix + key
+		if !strings.HasPrefix(key, "index.") {
+			key = "index." + key
+		}
+		flat[key] = value
+	}
+}
+
+// MergeSettings returns the JSON encoded flat index settings of the defaults
+// overridden by the provided JSON encoded settings.
+func MergeSettings(settings string, defaults map[string]interface{}) (string, error) {
+	flat, err := FlattenSettings(settings)
+	if err != nil {
+		return "", err
+	}
+	merged := make(map[string]interface{})
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range flat {
+		merged[key] = value
+	}
+	bytes, err := json.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
@@ -0,0 +1,42 @@
+package deluge
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const snapshotSuccess = "SUCCESS"
+
+// SnapshotStatus represents the outcome of a completed snapshot.
+type SnapshotStatus struct {
+	Repository   string
+	Snapshot     string
+	State        string
+	Indices      []string
+	TotalShards  int
+	FailedShards int
+	Failures     []string
+	Duration     time.Duration
+}
+
+// Succeeded returns whether every shard of every index was snapshotted.
+func (s *SnapshotStatus) Succeeded() bool {
+	return s.State == snapshotSuccess
+}
+
+// String returns a string containing the status information.
+func (s *SnapshotStatus) String() string {
+	str := fmt.Sprintf("Snapshot `%s` of %s to repository `%s` completed with state %s in %v, %d of %d shards failed",
+		s.Snapshot,
+		strings.Join(s.Indices, ", "),
+		s.Repository,
+		s.State,
+		s.Duration,
+		s.FailedShards,
+		s.TotalShards)
+	if len(s.Failures) > 0 {
+		str += ": " + strings.Join(s.Failures, ", ")
+	}
+	return str
+}
@@ -0,0 +1,51 @@
+package deluge
+
+import (
+	"encoding/json"
+)
+
+const (
+	lifecycleSetting = "index.lifecycle.name"
+)
+
+// IndexTemplate represents an index template that applies a mapping and
+// settings to new indices whose names match its patterns.
+type IndexTemplate struct {
+	Name string
+	// Legacy installs the template through the legacy _template API instead
+	// of as a composable index template.
+	Legacy   bool
+	Patterns []string
+	// Priority decides between overlapping templates. It is used as the order
+	// of legacy templates.
+	Priority int
+	// ComposedOf are the names of the component templates that make up a
+	// composable template. It is ignored for legacy templates.
+	ComposedOf []string
+	// Settings and Mapping are the JSON encoded index settings and mapping.
+	Settings string
+	Mapping  string
+}
+
+type namedBody struct {
+	name string
+	body string
+}
+
+// setDefaultSetting returns the JSON encoded settings with the setting added,
+// unless the setting is already present.
+func setDefaultSetting(settings string, key string, value interface{}) (string, error) {
+	flat, err := FlattenSettings(settings)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := flat[key]; ok {
+		return settings, nil
+	}
+	flat[key] = value
+	bytes, err := json.Marshal(flat)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
@@ -11,11 +11,22 @@ const (
 	minimumToCheck = 10
 )
 
-var (
-	errs    = make([]error, 0)
-	mu      = sync.Mutex{}
-	success uint64
-)
+// Tracker tracks document errors and successes against an error threshold.
+type Tracker struct {
+	threshold float64
+	errs      []error
+	mu        *sync.Mutex
+	success   uint64
+}
+
+// NewTracker instantiates a new tracker for the provided threshold.
+func NewTracker(threshold float64) *Tracker {
+	return &Tracker{
+		threshold: threshold,
+		errs:      make([]error, 0),
+		mu:        &sync.Mutex{},
+	}
+}
 
 // NewErr returns a threshold overflow error.
 func NewErr(threshold float64) error {
@@ -23,37 +34,63 @@ func NewErr(threshold float64) error {
 		threshold)
 }
 
+// Err returns the threshold overflow error for the tracker.
+func (t *Tracker) Err() error {
+	return NewErr(t.threshold)
+}
+
 // CheckErr checks if an error exsits and if so adds the error to the error
-// checking count.
-func CheckErr(err error, threshold float64) bool {
+// checking count. Returns true if the threshold has been surpassed.
+func (t *Tracker) CheckErr(err error) bool {
 	if err == nil {
 		return false
 	}
-	mu.Lock()
-	errs = append(errs, err)
-	numErrors := uint64(len(errs))
-	numTotal := numErrors + success
-	mu.Unlock()
+	t.mu.Lock()
+	t.errs = append(t.errs, err)
+	numErrors := uint64(len(t.errs))
+	numTotal := numErrors + atomic.LoadUint64(&t.success)
+	t.mu.Unlock()
 	// don't fail unless until a minimum number of docs have been processed
 	if numTotal < minimumToCheck {
 		return false
 	}
-	ratio := 1.0 - (float64(atomic.LoadUint64(&success)) / float64(numErrors))
-	return ratio > threshold
+	ratio := 1.0 - (float64(atomic.LoadUint64(&t.success)) / float64(numErrors))
+	return ratio > t.threshold
+}
+
+// CheckRejected records an error for a document that was previously counted
+// as a success, ex. a document rejected by elasticsearch after being parsed.
+// Returns true if the threshold has been surpassed.
+func (t *Tracker) CheckRejected(err error) bool {
+	if err == nil {
+		return false
+	}
+	for {
+		success := atomic.LoadUint64(&t.success)
+		if success == 0 || atomic.CompareAndSwapUint64(&t.success, success, success-1) {
+			break
+		}
+	}
+	return t.CheckErr(err)
 }
 
 // AddSuccess adds a success to the success count.
-func AddSuccess() {
-	atomic.AddUint64(&success, 1)
+func (t *Tracker) AddSuccess() {
+	atomic.AddUint64(&t.success, 1)
 }
 
 // Errs returns all ingestion errors.
-func Errs() []error {
+func (t *Tracker) Errs() []error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	errs := make([]error, len(t.errs))
+	copy(errs, t.errs)
 	return errs
 }
 
 // SampleErrs returns an N sized sample of errors.
-func SampleErrs(n int) []error {
+func (t *Tracker) SampleErrs(n int) []error {
+	errs := t.Errs()
 	if len(errs) < n {
 		return errs
 	}
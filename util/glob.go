@@ -0,0 +1,48 @@
+package util
+
+import (
+	"path"
+	"strings"
+)
+
+// ValidateGlob returns an error if the glob pattern is malformed.
+func ValidateGlob(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MatchGlob returns true if the slash separated path matches the glob pattern.
+// Patterns are matched segment by segment using path.Match, where a `**`
+// segment matches zero or more directories, ex. `**/part-*.json.gz`. Patterns
+// without a `**` segment only match paths with the same number of segments.
+func MatchGlob(pattern string, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(patterns []string, names []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			// try every number of directories the wildcard could match
+			for i := 0; i <= len(names); i++ {
+				if matchSegments(patterns[1:], names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		matched, err := path.Match(patterns[0], names[0])
+		if err != nil || !matched {
+			return false
+		}
+		patterns = patterns[1:]
+		names = names[1:]
+	}
+	return len(names) == 0
+}
@@ -0,0 +1,51 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestValidateGlob(t *testing.T) {
+	valid := []string{"*.json", "**/part-*.json.gz", "a/b/c", "[a-z]?/**"}
+	for _, pattern := range valid {
+		if err := ValidateGlob(pattern); err != nil {
+			t.Errorf("expected `%s` to be valid, got %v", pattern, err)
+		}
+	}
+	invalid := []string{"[", "a/[b", "**/\\", "a/[-]/b"}
+	for _, pattern := range invalid {
+		if err := ValidateGlob(pattern); err == nil {
+			t.Errorf("expected `%s` to be invalid", pattern)
+		}
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		{"*.json", "a.json", true},
+		{"*.json", "dir/a.json", false},
+		{"dir/*.json", "dir/a.json", true},
+		{"dir/*.json", "dir/sub/a.json", false},
+		{"**/*.json", "a.json", true},
+		{"**/*.json", "dir/sub/a.json", true},
+		{"**/*.json", "dir/sub/a.csv", false},
+		{"dir/**", "dir", true},
+		{"dir/**", "dir/sub/a.json", true},
+		{"dir/**", "other/a.json", false},
+		{"a/**/b/*.gz", "a/b/part.gz", true},
+		{"a/**/b/*.gz", "a/x/y/b/part.gz", true},
+		{"a/**/b/*.gz", "a/x/y/c/part.gz", false},
+		{"**", "a/b/c", true},
+		{"part-?.json", "part-1.json", true},
+		{"part-?.json", "part-10.json", false},
+		{"[", "[", false},
+	}
+	for _, test := range tests {
+		if MatchGlob(test.pattern, test.name) != test.expected {
+			t.Errorf("expected MatchGlob(%q, %q) to be %v", test.pattern, test.name, test.expected)
+		}
+	}
+}
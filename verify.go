@@ -0,0 +1,138 @@
+package deluge
+
+import (
+	"fmt"
+	"sync"
+)
+
+// VerifySummary represents the results of verifying the document count of an
+// index against the documents successfully submitted to it.
+type VerifySummary struct {
+	Index string
+	// NumSubmitted is the number of indexed, created and upserted documents
+	// acknowledged by elasticsearch.
+	NumSubmitted int64
+	// NumUnique is the number of unique ids among the submitted documents,
+	// excluding those subsequently deleted, which is the expected count.
+	NumUnique int64
+	// NumDuplicates is the number of submitted documents that were collapsed
+	// into an earlier document with the same id and routing.
+	NumDuplicates int64
+	// NumDocs is the number of documents counted in the index.
+	NumDocs int64
+	// NumMissing is the number of expected documents absent from the index.
+	NumMissing int64
+	// NumExtra is the number of documents in the index that were not
+	// submitted by the ingest, such as those ingested by a previous run.
+	NumExtra int64
+}
+
+// MissingRate returns the ratio of missing documents to expected documents.
+func (s *VerifySummary) MissingRate() float64 {
+	if s.NumUnique == 0 {
+		return 0
+	}
+	return float64(s.NumMissing) / float64(s.NumUnique)
+}
+
+// String returns a string containing the summary information.
+func (s *VerifySummary) String() string {
+	return fmt.Sprintf("Verified index `%s` contains %d docs, expected %d unique ids from %d submitted docs (%d duplicates collapsed by id, %d missing, %d extra)",
+		s.Index,
+		s.NumDocs,
+		s.NumUnique,
+		s.NumSubmitted,
+		s.NumDuplicates,
+		s.NumMissing,
+		s.NumExtra)
+}
+
+// verifier tracks the unique ids of the documents acknowledged for each index.
+type verifier struct {
+	indices map[string]*verifiedIndex
+	mutex   *sync.Mutex
+}
+
+type verifiedIndex struct {
+	ids        map[string]bool
+	submitted  int64
+	duplicates int64
+}
+
+func newVerifier() *verifier {
+	return &verifier{
+		indices: make(map[string]*verifiedIndex),
+		mutex:   &sync.Mutex{},
+	}
+}
+
+// verifyKey returns the key identifying the document. Documents with the same
+// id but different routing values may be stored on different shards, and are
+// therefore not collapsed.
+func verifyKey(item *BulkItem) string {
+	if item.Metadata == nil || item.Metadata.Routing == "" {
+		return item.ID
+	}
+	return item.Metadata.Routing + "\x00" + item.ID
+}
+
+// addAccepted records the items of a bulk request that were not rejected.
+// Items without an index are ingested into the provided target index.
+func (v *verifier) addAccepted(target string, items []*BulkItem, failed []*BulkItemError) {
+	rejected := make(map[int]bool, len(failed))
+	for _, item := range failed {
+		rejected[item.Item] = true
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	for n, item := range items {
+		if rejected[n] {
+			continue
+		}
+		name := item.Index
+		if name == "" {
+			name = target
+		}
+		index, ok := v.indices[name]
+		if !ok {
+			index = &verifiedIndex{
+				ids: make(map[string]bool),
+			}
+			v.indices[name] = index
+		}
+		key := verifyKey(item)
+		switch item.Operation {
+		case OperationIndex, OperationCreate, OperationUpsert:
+			index.submitted++
+			if index.ids[key] {
+				index.duplicates++
+			}
+			index.ids[key] = true
+		case OperationDelete:
+			delete(index.ids, key)
+		}
+		// updates only modify existing documents
+	}
+}
+
+// summary returns the summary of the index given the number of documents
+// counted in it.
+func (v *verifier) summary(name string, count int64) *VerifySummary {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	summary := &VerifySummary{
+		Index:   name,
+		NumDocs: count,
+	}
+	if index, ok := v.indices[name]; ok {
+		summary.NumSubmitted = index.submitted
+		summary.NumUnique = int64(len(index.ids))
+		summary.NumDuplicates = index.duplicates
+	}
+	if count < summary.NumUnique {
+		summary.NumMissing = summary.NumUnique - count
+	} else {
+		summary.NumExtra = count - summary.NumUnique
+	}
+	return summary
+}